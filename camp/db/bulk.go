@@ -0,0 +1,246 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// mysql单条预处理语句最多65535个占位符
+	defaultMaxPlaceholders = 65535
+	// 默认按mysql 5.7的 max_allowed_packet 4MB 估算，留出余量
+	defaultMaxStatementSize = 4<<20 - 64<<10
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, sql string, args ...interface{}) (sql.Result, error)
+}
+
+// BulkInsert 批量插入，行数较多时按占位符数量和语句大小自动分片执行
+type BulkInsert struct {
+	Table   string
+	Columns []string
+	Rows    [][]interface{}
+	// UpdateColumns 不为空时追加 ON DUPLICATE KEY UPDATE col = VALUES(col)
+	UpdateColumns []string
+	// MaxPlaceholders 单条语句最多占位符数，为0时使用65535
+	MaxPlaceholders int
+	// MaxStatementSize 单条语句的估算字节数上限，为0时按4MB的 max_allowed_packet 计算
+	MaxStatementSize int
+	// Transaction 为true时所有分片在同一个事务中执行，在 Tx 上调用时忽略
+	Transaction bool
+}
+
+// NewBulkInsert 从结构体切片生成 BulkInsert，列名规则同 Select。
+// columns 为空时使用结构体的全部列，否则只插入指定的列
+func NewBulkInsert(table string, rows interface{}, columns ...string) (BulkInsert, error) {
+	b := BulkInsert{Table: table}
+	v := reflect.Indirect(reflect.ValueOf(rows))
+	if v.Kind() != reflect.Slice {
+		return b, fmt.Errorf("db: NewBulkInsert(non-slice %T)", rows)
+	}
+	elemType := v.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if !isStructDest(elemType) {
+		return b, fmt.Errorf("db: NewBulkInsert(non-struct element %s)", elemType)
+	}
+	plan := fieldPlan(elemType)
+	if len(columns) == 0 {
+		columns = orderedColumns(plan)
+	}
+	for _, column := range columns {
+		if _, ok := plan[column]; !ok {
+			return b, fmt.Errorf("db: missing column %q in %s", column, elemType)
+		}
+	}
+	b.Columns = columns
+	b.Rows = make([][]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elem := reflect.Indirect(v.Index(i))
+		if !elem.IsValid() {
+			return b, fmt.Errorf("db: NewBulkInsert nil element at index %d", i)
+		}
+		row := make([]interface{}, len(columns))
+		for j, column := range columns {
+			field, err := elem.FieldByIndexErr(plan[column])
+			if err == nil {
+				row[j] = field.Interface()
+			}
+		}
+		b.Rows = append(b.Rows, row)
+	}
+	return b, nil
+}
+
+// orderedColumns 按字段声明顺序返回列名
+func orderedColumns(plan map[string][]int) []string {
+	columns := make([]string, 0, len(plan))
+	for column := range plan {
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		a, b := plan[columns[i]], plan[columns[j]]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return columns
+}
+
+// BulkInsert 批量插入，返回受影响的总行数
+func (s *Db) BulkInsert(ctx context.Context, b BulkInsert) (int64, error) {
+	if !b.Transaction {
+		return b.exec(ctx, s)
+	}
+	var affected int64
+	err := s.WithTransactionContext(ctx, nil, func(tx *Tx) error {
+		var err error
+		affected, err = b.exec(ctx, tx)
+		return err
+	})
+	return affected, err
+}
+
+// BulkInsert 在事务中批量插入，返回受影响的总行数
+func (s *Tx) BulkInsert(ctx context.Context, b BulkInsert) (int64, error) {
+	return b.exec(ctx, s)
+}
+
+func (b BulkInsert) exec(ctx context.Context, e execer) (int64, error) {
+	statements, err := b.Build()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, st := range statements {
+		result, err := e.ExecContext(ctx, st.Query, st.Args...)
+		if err != nil {
+			return total, err
+		}
+		affected, _ := result.RowsAffected()
+		total += affected
+	}
+	return total, nil
+}
+
+// BulkStatement 分片后的单条语句
+type BulkStatement struct {
+	Query string
+	Args  []interface{}
+}
+
+// Build 生成分片后的语句，不执行
+func (b BulkInsert) Build() ([]BulkStatement, error) {
+	if b.Table == "" || len(b.Columns) == 0 {
+		return nil, errors.New("db: bulk insert requires table and columns")
+	}
+	if len(b.Rows) == 0 {
+		return nil, nil
+	}
+	maxPlaceholders := b.MaxPlaceholders
+	if maxPlaceholders <= 0 {
+		maxPlaceholders = defaultMaxPlaceholders
+	}
+	maxSize := b.MaxStatementSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxStatementSize
+	}
+	if len(b.Columns) > maxPlaceholders {
+		return nil, fmt.Errorf("db: %d columns exceed %d placeholders", len(b.Columns), maxPlaceholders)
+	}
+
+	prefix := b.prefix()
+	suffix := b.suffix()
+	rowSQL := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(b.Columns)), ", ") + ")"
+
+	var (
+		statements []BulkStatement
+		buf        strings.Builder
+		args       []interface{}
+		size       int
+	)
+	flush := func() {
+		if len(args) == 0 {
+			return
+		}
+		buf.WriteString(suffix)
+		statements = append(statements, BulkStatement{Query: buf.String(), Args: args})
+		buf.Reset()
+		args = nil
+		size = 0
+	}
+	for i, row := range b.Rows {
+		if len(row) != len(b.Columns) {
+			return nil, fmt.Errorf("db: row %d has %d values, want %d", i, len(row), len(b.Columns))
+		}
+		rowSize := len(rowSQL) + 2
+		for _, v := range row {
+			rowSize += estimateSize(v)
+		}
+		if len(args) > 0 && (len(args)+len(row) > maxPlaceholders || size+rowSize+len(suffix) > maxSize) {
+			flush()
+		}
+		if len(args) == 0 {
+			buf.WriteString(prefix)
+			size = len(prefix)
+		} else {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(rowSQL)
+		args = append(args, row...)
+		size += rowSize
+	}
+	flush()
+	return statements, nil
+}
+
+func (b BulkInsert) prefix() string {
+	columns := make([]string, len(b.Columns))
+	for i, column := range b.Columns {
+		columns[i] = QuoteIdentifier(column)
+	}
+	return "INSERT INTO " + QuoteIdentifier(b.Table) + " (" + strings.Join(columns, ", ") + ") VALUES "
+}
+
+func (b BulkInsert) suffix() string {
+	if len(b.UpdateColumns) == 0 {
+		return ""
+	}
+	updates := make([]string, len(b.UpdateColumns))
+	for i, column := range b.UpdateColumns {
+		column = QuoteIdentifier(column)
+		updates[i] = column + " = VALUES(" + column + ")"
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+// QuoteIdentifier 用反引号包裹标识符，支持 db.table 形式，标识符中的反引号会被转义
+func QuoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+// estimateSize 估算参数在mysql协议中占用的字节数
+func estimateSize(v interface{}) int {
+	switch v := v.(type) {
+	case string:
+		return len(v) + 9
+	case []byte:
+		return len(v) + 9
+	default:
+		return 9
+	}
+}
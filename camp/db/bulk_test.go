@@ -0,0 +1,149 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBulkInsertBuild(t *testing.T) {
+	// 单列字符串行的估算大小：len("(?)")+2+len(s)+9 = 24，前缀 "INSERT INTO `t` (`a`) VALUES " 为29字节
+	s := "0123456789"
+	strRows := [][]interface{}{{s}, {s}, {s}}
+	const update = " ON DUPLICATE KEY UPDATE `a` = VALUES(`a`)"
+
+	tests := []struct {
+		name        string
+		b           BulkInsert
+		wantQueries []string
+		wantArgs    [][]interface{}
+		wantErr     string
+	}{
+		{
+			name: "single statement",
+			b:    BulkInsert{Table: "t", Columns: []string{"a", "b"}, Rows: [][]interface{}{{1, "x"}, {2, []byte("y")}}},
+			wantQueries: []string{
+				"INSERT INTO `t` (`a`, `b`) VALUES (?, ?), (?, ?)",
+			},
+			wantArgs: [][]interface{}{{1, "x", 2, []byte("y")}},
+		},
+		{
+			name: "quoted identifiers",
+			b:    BulkInsert{Table: "app.user", Columns: []string{"a`b"}, Rows: [][]interface{}{{1}}},
+			wantQueries: []string{
+				"INSERT INTO `app`.`user` (`a``b`) VALUES (?)",
+			},
+			wantArgs: [][]interface{}{{1}},
+		},
+		{
+			name: "on duplicate key update",
+			b: BulkInsert{Table: "t", Columns: []string{"id", "a", "b"}, UpdateColumns: []string{"a", "b"},
+				Rows: [][]interface{}{{1, 2, 3}}},
+			wantQueries: []string{
+				"INSERT INTO `t` (`id`, `a`, `b`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `a` = VALUES(`a`), `b` = VALUES(`b`)",
+			},
+			wantArgs: [][]interface{}{{1, 2, 3}},
+		},
+		{
+			name: "placeholders exactly fill one statement",
+			b:    BulkInsert{Table: "t", Columns: []string{"a", "b"}, MaxPlaceholders: 4, Rows: [][]interface{}{{1, 2}, {3, 4}}},
+			wantQueries: []string{
+				"INSERT INTO `t` (`a`, `b`) VALUES (?, ?), (?, ?)",
+			},
+			wantArgs: [][]interface{}{{1, 2, 3, 4}},
+		},
+		{
+			name: "placeholders split with suffix on every chunk",
+			b: BulkInsert{Table: "t", Columns: []string{"a", "b"}, UpdateColumns: []string{"b"}, MaxPlaceholders: 4,
+				Rows: [][]interface{}{{1, 2}, {3, 4}, {5, 6}}},
+			wantQueries: []string{
+				"INSERT INTO `t` (`a`, `b`) VALUES (?, ?), (?, ?) ON DUPLICATE KEY UPDATE `b` = VALUES(`b`)",
+				"INSERT INTO `t` (`a`, `b`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `b` = VALUES(`b`)",
+			},
+			wantArgs: [][]interface{}{{1, 2, 3, 4}, {5, 6}},
+		},
+		{
+			name: "statement size exactly fits two rows",
+			b:    BulkInsert{Table: "t", Columns: []string{"a"}, MaxStatementSize: 29 + 2*24, Rows: strRows},
+			wantQueries: []string{
+				"INSERT INTO `t` (`a`) VALUES (?), (?)",
+				"INSERT INTO `t` (`a`) VALUES (?)",
+			},
+			wantArgs: [][]interface{}{{s, s}, {s}},
+		},
+		{
+			name: "statement size one byte short",
+			b:    BulkInsert{Table: "t", Columns: []string{"a"}, MaxStatementSize: 29 + 2*24 - 1, Rows: strRows},
+			wantQueries: []string{
+				"INSERT INTO `t` (`a`) VALUES (?)",
+				"INSERT INTO `t` (`a`) VALUES (?)",
+				"INSERT INTO `t` (`a`) VALUES (?)",
+			},
+			wantArgs: [][]interface{}{{s}, {s}, {s}},
+		},
+		{
+			name: "statement size counts suffix",
+			b: BulkInsert{Table: "t", Columns: []string{"a"}, UpdateColumns: []string{"a"},
+				MaxStatementSize: 29 + 2*24 + len(update) - 1, Rows: strRows[:2]},
+			wantQueries: []string{
+				"INSERT INTO `t` (`a`) VALUES (?)" + update,
+				"INSERT INTO `t` (`a`) VALUES (?)" + update,
+			},
+			wantArgs: [][]interface{}{{s}, {s}},
+		},
+		{
+			name: "oversized row gets its own statement",
+			b:    BulkInsert{Table: "t", Columns: []string{"a"}, MaxStatementSize: 10, Rows: strRows[:2]},
+			wantQueries: []string{
+				"INSERT INTO `t` (`a`) VALUES (?)",
+				"INSERT INTO `t` (`a`) VALUES (?)",
+			},
+			wantArgs: [][]interface{}{{s}, {s}},
+		},
+		{
+			name: "no rows",
+			b:    BulkInsert{Table: "t", Columns: []string{"a"}},
+		},
+		{
+			name:    "no columns",
+			b:       BulkInsert{Table: "t", Rows: [][]interface{}{{1}}},
+			wantErr: "requires table and columns",
+		},
+		{
+			name:    "row length mismatch",
+			b:       BulkInsert{Table: "t", Columns: []string{"a", "b"}, Rows: [][]interface{}{{1, 2}, {3}}},
+			wantErr: "row 1 has 1 values, want 2",
+		},
+		{
+			name:    "columns exceed placeholders",
+			b:       BulkInsert{Table: "t", Columns: []string{"a", "b", "c"}, MaxPlaceholders: 2, Rows: [][]interface{}{{1, 2, 3}}},
+			wantErr: "3 columns exceed 2 placeholders",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements, err := tt.b.Build()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var queries []string
+			var args [][]interface{}
+			for _, st := range statements {
+				queries = append(queries, st.Query)
+				args = append(args, st.Args)
+			}
+			if !reflect.DeepEqual(queries, tt.wantQueries) {
+				t.Errorf("queries\n got %q\nwant %q", queries, tt.wantQueries)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args\n got %v\nwant %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -1,10 +1,19 @@
 package db
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 var (
@@ -21,3 +30,200 @@ func SetLogOutput(w io.Writer) {
 	output = w
 	logger.SetOutput(w)
 }
+
+// 默认连接参数，与原Connect中写死的值保持一致
+const (
+	DefaultCharset      = "utf8mb4"
+	DefaultMaxOpenConns = 200
+	DefaultMaxIdleConns = 100
+)
+
+var (
+	tlsConfigSeq int64
+	// tlsConfigNames 已注册的 *tls.Config 到注册名的映射
+	tlsConfigNames sync.Map
+	tlsConfigMutex sync.Mutex
+)
+
+// Config mysql连接配置
+type Config struct {
+	// Net 网络类型，默认为tcp，也可以是 mysql.RegisterDialContext 注册的名称
+	Net      string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+
+	Charset   string
+	Collation string
+	// Loc 解析DATETIME时使用的时区，为nil时使用UTC
+	Loc       *time.Location
+	ParseTime bool
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// TLS mysql驱动的tls参数，可为true、false、skip-verify、preferred或已注册的名称
+	TLS string
+	// TLSConfig 自定义tls配置，不为nil时优先于TLS
+	TLSConfig *tls.Config
+
+	// Params 其他dsn参数，如 autocommit、sql_mode 等
+	Params map[string]string
+}
+
+// NewConfig 返回带默认值的配置
+func NewConfig(host string, port int, user, pwd, database string) Config {
+	return Config{
+		Host:         host,
+		Port:         port,
+		User:         user,
+		Password:     pwd,
+		Database:     database,
+		Charset:      DefaultCharset,
+		MaxOpenConns: DefaultMaxOpenConns,
+		MaxIdleConns: DefaultMaxIdleConns,
+	}
+}
+
+// RegisterTLS 向mysql驱动注册TLSConfig，同一个TLSConfig只注册一次。
+// ConnectWithConfig 等方法会自动调用，单独使用 FormatDSN 时需先调用
+func (c Config) RegisterTLS() error {
+	if c.TLSConfig == nil {
+		return nil
+	}
+	tlsConfigMutex.Lock()
+	defer tlsConfigMutex.Unlock()
+	if _, ok := tlsConfigNames.Load(c.TLSConfig); ok {
+		return nil
+	}
+	name := fmt.Sprintf("golang-base-tls-%d", atomic.AddInt64(&tlsConfigSeq, 1))
+	if err := mysql.RegisterTLSConfig(name, c.TLSConfig); err != nil {
+		return err
+	}
+	tlsConfigNames.Store(c.TLSConfig, name)
+	return nil
+}
+
+// FormatDSN 生成mysql驱动使用的dsn
+func (c Config) FormatDSN() (string, error) {
+	m := mysql.NewConfig()
+	m.User = c.User
+	m.Passwd = c.Password
+	m.Net = c.Net
+	if m.Net == "" {
+		m.Net = "tcp"
+	}
+	m.Addr = c.Host
+	if c.Port > 0 {
+		m.Addr = c.Host + ":" + strconv.Itoa(c.Port)
+	}
+	m.DBName = c.Database
+	m.Collation = c.Collation
+	if c.Loc != nil {
+		m.Loc = c.Loc
+	}
+	m.ParseTime = c.ParseTime
+	m.Timeout = c.DialTimeout
+	m.ReadTimeout = c.ReadTimeout
+	m.WriteTimeout = c.WriteTimeout
+	m.TLSConfig = c.TLS
+	if c.TLSConfig != nil {
+		name, ok := tlsConfigNames.Load(c.TLSConfig)
+		if !ok {
+			return "", errors.New("db: TLSConfig not registered, call RegisterTLS first")
+		}
+		m.TLSConfig = name.(string)
+	}
+	m.Params = map[string]string{}
+	if c.Charset != "" {
+		m.Params["charset"] = c.Charset
+	}
+	for k, v := range c.Params {
+		m.Params[k] = v
+	}
+	return m.FormatDSN(), nil
+}
+
+// ConfigFromEnv 从环境变量读取配置，变量名为 prefix_HOST、prefix_PORT 等，
+// 未设置的项使用 NewConfig 的默认值
+func ConfigFromEnv(prefix string) (Config, error) {
+	if prefix != "" {
+		prefix += "_"
+	}
+	env := func(key string) string {
+		return os.Getenv(prefix + key)
+	}
+	c := NewConfig(env("HOST"), 3306, env("USER"), env("PASSWORD"), env("DATABASE"))
+	var err error
+	if v := env("PORT"); v != "" {
+		if c.Port, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("db: invalid %sPORT: %w", prefix, err)
+		}
+	}
+	if v := env("CHARSET"); v != "" {
+		c.Charset = v
+	}
+	c.Collation = env("COLLATION")
+	if v := env("LOC"); v != "" {
+		if c.Loc, err = time.LoadLocation(v); err != nil {
+			return c, fmt.Errorf("db: invalid %sLOC: %w", prefix, err)
+		}
+	}
+	if v := env("PARSE_TIME"); v != "" {
+		if c.ParseTime, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("db: invalid %sPARSE_TIME: %w", prefix, err)
+		}
+	}
+	ints := []struct {
+		key string
+		dst *int
+	}{
+		{"MAX_OPEN_CONNS", &c.MaxOpenConns},
+		{"MAX_IDLE_CONNS", &c.MaxIdleConns},
+	}
+	for _, i := range ints {
+		if v := env(i.key); v != "" {
+			if *i.dst, err = strconv.Atoi(v); err != nil {
+				return c, fmt.Errorf("db: invalid %s%s: %w", prefix, i.key, err)
+			}
+		}
+	}
+	durations := []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"CONN_MAX_LIFETIME", &c.ConnMaxLifetime},
+		{"CONN_MAX_IDLE_TIME", &c.ConnMaxIdleTime},
+		{"DIAL_TIMEOUT", &c.DialTimeout},
+		{"READ_TIMEOUT", &c.ReadTimeout},
+		{"WRITE_TIMEOUT", &c.WriteTimeout},
+	}
+	for _, d := range durations {
+		if v := env(d.key); v != "" {
+			if *d.dst, err = time.ParseDuration(v); err != nil {
+				return c, fmt.Errorf("db: invalid %s%s: %w", prefix, d.key, err)
+			}
+		}
+	}
+	c.TLS = env("TLS")
+	// PARAMS 格式同url query，如 autocommit=true&sql_mode=TRADITIONAL
+	if v := env("PARAMS"); v != "" {
+		values, err := url.ParseQuery(v)
+		if err != nil {
+			return c, fmt.Errorf("db: invalid %sPARAMS: %w", prefix, err)
+		}
+		c.Params = make(map[string]string, len(values))
+		for k := range values {
+			c.Params[k] = values.Get(k)
+		}
+	}
+	return c, nil
+}
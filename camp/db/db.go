@@ -4,27 +4,94 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	"github.com/mufe/golang-base/camp/sqlfmt"
 	"github.com/mufe/golang-base/camp/xlog"
 	"golang.org/x/crypto/ssh"
 	"io"
 	"io/ioutil"
 	"net"
 	"os"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type ViaSSHDialer struct {
-	client *ssh.Client
+	client *Client
 	_      *context.Context
 }
 
-func (self *ViaSSHDialer) Dial(context context.Context, addr string) (net.Conn, error) {
-	return self.client.Dial("tcp", addr)
+// keepaliveTimeout bounds the probe that decides whether a failed dial
+// means the ssh transport itself is gone.
+const keepaliveTimeout = 5 * time.Second
+
+// Dial connects to addr through the ssh tunnel. The tunnel is reconnected once,
+// but only when the ssh transport is dead: a channel the server refused (mysql
+// down, connection refused) leaves the shared client and its other connections alone.
+func (self *ViaSSHDialer) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	current := self.client.sshClient()
+	conn, err := dialContext(ctx, current, addr)
+	if err == nil {
+		return conn, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+	var openErr *ssh.OpenChannelError
+	if errors.As(err, &openErr) || transportAlive(ctx, current) {
+		return nil, err
+	}
+	if err := self.client.reconnect(current); err != nil {
+		return nil, err
+	}
+	return dialContext(ctx, self.client.sshClient(), addr)
+}
+
+// dialContext opens a direct-tcpip channel, giving up when ctx is done.
+func dialContext(ctx context.Context, client *ssh.Client, addr string) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := client.Dial("tcp", addr)
+		done <- result{conn, err}
+	}()
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-ctx.Done():
+		// close the channel if it opens after the caller gave up
+		go func() {
+			if r := <-done; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// transportAlive reports whether the ssh connection still answers a keepalive request.
+func transportAlive(ctx context.Context, client *ssh.Client) bool {
+	ctx, cancel := context.WithTimeout(ctx, keepaliveTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err == nil
+	case <-ctx.Done():
+		return false
+	}
 }
 
 type remoteScriptType byte
@@ -40,95 +107,149 @@ const (
 )
 
 type Db struct {
-	db    *sql.DB
+	db       *sql.DB
+	replicas *replicaSet
+	tunnel   *Client
+	// Print 为true时连接池的每条语句经日志钩子按 Log 输出(默认经 xlog.DB)，需在 Connect 之前设置
 	Print bool
+	// Log Print 为true时的日志配置，为nil时使用 DefaultSlowThreshold
+	Log      *LogConfig
+	logOnce  sync.Once
+	logHooks []Hook
+	analyzer *SlowQueryAnalyzer
+	// formatter 日志中嵌入参数的格式，时区与连接的 Loc 一致
+	formatter *sqlfmt.Formatter
+	// Retry 不为nil时，WithTransaction 遇到死锁、锁等待超时会按策略重试整个事务
+	Retry *RetryPolicy
 }
 
 type Client struct {
 	client *ssh.Client
+	mutex  sync.RWMutex
+
+	// dial establishes a new connection, used by Reconnect
+	dial func() (*ssh.Client, error)
+	// closers are closed together with the client, e.g. the jump hosts or the agent connection
+	closers []io.Closer
 }
 
 // DialWithPasswd starts a client connection to the given SSH server with passwd authmethod.
-func DialWithPasswd(addr, user, passwd string) (*Client, error) {
-
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(passwd),
-		},
-		HostKeyCallback: ssh.HostKeyCallback(func(hostname string, remote net.Addr, key ssh.PublicKey) error { return nil }),
+// The host key is verified against ~/.ssh/known_hosts unless a host key option is given.
+func DialWithPasswd(addr, user, passwd string, opts ...DialOption) (*Client, error) {
+	config, err := NewClientConfig(user, []ssh.AuthMethod{
+		ssh.Password(passwd),
+	}, opts...)
+	if err != nil {
+		return nil, err
 	}
 
 	return Dial("tcp", addr, config)
 }
 
 // DialWithKey starts a client connection to the given SSH server with key authmethod.
-func DialWithKey(addr, user, keyfile string) (*Client, error) {
-	key, err := ioutil.ReadFile(keyfile)
+func DialWithKey(addr, user, keyfile string, opts ...DialOption) (*Client, error) {
+	return DialWithKeyWithPassphrase(addr, user, keyfile, "", opts...)
+}
+
+// DialWithKeyWithPassphrase same as DialWithKey but with a passphrase to decrypt the private key
+func DialWithKeyWithPassphrase(addr, user, keyfile string, passphrase string, opts ...DialOption) (*Client, error) {
+	auth, err := KeyAuth(keyfile, passphrase)
 	if err != nil {
 		return nil, err
 	}
 
-	signer, err := ssh.ParsePrivateKey(key)
+	config, err := NewClientConfig(user, []ssh.AuthMethod{auth}, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.HostKeyCallback(func(hostname string, remote net.Addr, key ssh.PublicKey) error { return nil }),
-	}
-
 	return Dial("tcp", addr, config)
 }
 
-// DialWithKeyWithPassphrase same as DialWithKey but with a passphrase to decrypt the private key
-func DialWithKeyWithPassphrase(addr, user, keyfile string, passphrase string) (*Client, error) {
+// KeyAuth reads a private key file, passphrase may be empty for unencrypted keys.
+func KeyAuth(keyfile, passphrase string) (ssh.AuthMethod, error) {
 	key, err := ioutil.ReadFile(keyfile)
 	if err != nil {
 		return nil, err
 	}
 
-	signer, err := ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	var signer ssh.Signer
+	if passphrase == "" {
+		signer, err = ssh.ParsePrivateKey(key)
+	} else {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	}
 	if err != nil {
 		return nil, err
 	}
-
-	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.HostKeyCallback(func(hostname string, remote net.Addr, key ssh.PublicKey) error { return nil }),
-	}
-
-	return Dial("tcp", addr, config)
+	return ssh.PublicKeys(signer), nil
 }
 
 // Dial starts a client connection to the given SSH server.
 // This is wrap the ssh.Dial
 func Dial(network, addr string, config *ssh.ClientConfig) (*Client, error) {
-	client, err := ssh.Dial(network, addr, config)
+	return newClient(func() (*ssh.Client, error) {
+		return ssh.Dial(network, addr, config)
+	})
+}
+
+func newClient(dial func() (*ssh.Client, error)) (*Client, error) {
+	client, err := dial()
 	if err != nil {
 		return nil, err
 	}
 	return &Client{
 		client: client,
+		dial:   dial,
 	}, nil
 }
 
+// Reconnect re-establishes the ssh connection and closes the old one.
+func (c *Client) Reconnect() error {
+	return c.reconnect(nil)
+}
+
+// reconnect only reconnects when the current connection is still old,
+// so concurrent failures do not reconnect several times.
+func (c *Client) reconnect(old *ssh.Client) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if old != nil && c.client != old {
+		return nil
+	}
+	if c.dial == nil {
+		return errors.New("ssh client cannot reconnect")
+	}
+	client, err := c.dial()
+	if err != nil {
+		return err
+	}
+	if c.client != nil {
+		c.client.Close()
+	}
+	c.client = client
+	return nil
+}
+
+func (c *Client) sshClient() *ssh.Client {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.client
+}
+
 func (c *Client) Close() error {
-	return c.client.Close()
+	err := c.sshClient().Close()
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		c.closers[i].Close()
+	}
+	return err
 }
 
 // Cmd create a command on client
 func (c *Client) Cmd(cmd string) *remoteScript {
 	return &remoteScript{
 		_type:  cmdLine,
-		client: c.client,
+		client: c.sshClient(),
 		script: bytes.NewBufferString(cmd + "\n"),
 	}
 }
@@ -137,7 +258,7 @@ func (c *Client) Cmd(cmd string) *remoteScript {
 func (c *Client) Script(script string) *remoteScript {
 	return &remoteScript{
 		_type:  rawScript,
-		client: c.client,
+		client: c.sshClient(),
 		script: bytes.NewBufferString(script + "\n"),
 	}
 }
@@ -146,7 +267,7 @@ func (c *Client) Script(script string) *remoteScript {
 func (c *Client) ScriptFile(fname string) *remoteScript {
 	return &remoteScript{
 		_type:      scriptFile,
-		client:     c.client,
+		client:     c.sshClient(),
 		scriptFile: fname,
 	}
 }
@@ -160,24 +281,79 @@ type remoteScript struct {
 
 	stdout io.Writer
 	stderr io.Writer
+
+	continueOnError bool
+	timeout         time.Duration
+}
+
+// CmdResult is the outcome of one remote command, or of the whole script for Script and ScriptFile.
+type CmdResult struct {
+	Cmd string
+	// ExitCode is -1 when the command did not report an exit status,
+	// e.g. it was killed by a signal or the transport failed.
+	ExitCode int
+	// Signal is the name of the signal that terminated the command, if any.
+	Signal   string
+	Stdout   []byte
+	Stderr   []byte
+	Duration time.Duration
+	Err      error
+}
+
+// Success reports whether the command exited with status 0.
+func (r *CmdResult) Success() bool {
+	return r.Err == nil && r.ExitCode == 0
+}
+
+// RunResult holds the results of all executed commands.
+type RunResult struct {
+	Results  []CmdResult
+	Duration time.Duration
+}
+
+// Failed returns the results of the commands that did not succeed.
+func (r *RunResult) Failed() []CmdResult {
+	var failed []CmdResult
+	for _, result := range r.Results {
+		if !result.Success() {
+			failed = append(failed, result)
+		}
+	}
+	return failed
 }
 
 // Run
 func (rs *remoteScript) Run() error {
+	_, err := rs.RunContext(context.Background())
+	return err
+}
+
+// RunContext runs the script and returns the per command results. When ctx is done the
+// running command receives SIGTERM, its session is closed and ctx.Err() is returned.
+// The returned error is the first failure: a *ssh.ExitError for a non-zero exit,
+// or the transport error.
+func (rs *remoteScript) RunContext(ctx context.Context) (*RunResult, error) {
 	if rs.err != nil {
 		fmt.Println(rs.err)
-		return rs.err
+		return nil, rs.err
 	}
 
+	start := time.Now()
+	var (
+		result = &RunResult{}
+		err    error
+	)
 	if rs._type == cmdLine {
-		return rs.runCmds()
+		err = rs.runCmds(ctx, result)
 	} else if rs._type == rawScript {
-		return rs.runScript()
+		err = rs.runScript(ctx, result)
 	} else if rs._type == scriptFile {
-		return rs.runScriptFile()
+		err = rs.runScriptFile(ctx, result)
 	} else {
-		return errors.New("Not supported remoteScript type")
+		err = errors.New("Not supported remoteScript type")
 	}
+	result.Duration = time.Since(start)
+	return result, err
 }
 
 func (rs *remoteScript) Output() ([]byte, error) {
@@ -225,23 +401,91 @@ func (rs *remoteScript) SetStdio(stdout, stderr io.Writer) *remoteScript {
 	return rs
 }
 
-func (rs *remoteScript) runCmd(cmd string) error {
+// ContinueOnError keeps running the remaining commands after one fails.
+// The first failure is still returned by Run and RunContext.
+func (rs *remoteScript) ContinueOnError(b bool) *remoteScript {
+	rs.continueOnError = b
+	return rs
+}
+
+// SetTimeout limits the duration of each command, or of the whole script for Script and ScriptFile.
+func (rs *remoteScript) SetTimeout(timeout time.Duration) *remoteScript {
+	rs.timeout = timeout
+	return rs
+}
+
+// runSession runs cmd in a new session, or a shell fed from stdin when cmd is empty.
+func (rs *remoteScript) runSession(ctx context.Context, cmd string, stdin io.Reader) CmdResult {
+	result := CmdResult{Cmd: strings.TrimSpace(cmd), ExitCode: -1}
+	if rs.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rs.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
 	session, err := rs.client.NewSession()
 	if err != nil {
-		return err
+		result.Err = err
+		return result
 	}
 	defer session.Close()
 
-	session.Stdout = rs.stdout
-	session.Stderr = rs.stderr
+	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = teeWriter(&stdout, rs.stdout)
+	session.Stderr = teeWriter(&stderr, rs.stderr)
 
-	if err := session.Run(cmd); err != nil {
-		return err
+	if cmd == "" {
+		err = session.Shell()
+	} else {
+		err = session.Start(cmd)
 	}
-	return nil
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		session.Signal(ssh.SIGTERM)
+		session.Close()
+		<-done
+		err = ctx.Err()
+	}
+
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.Bytes()
+	result.Err = err
+	var exitErr *ssh.ExitError
+	if err == nil {
+		result.ExitCode = 0
+	} else if errors.As(err, &exitErr) {
+		result.Signal = exitErr.Signal()
+		if result.Signal == "" {
+			result.ExitCode = exitErr.ExitStatus()
+		}
+	}
+	return result
 }
 
-func (rs *remoteScript) runCmds() error {
+func teeWriter(buf *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, w)
+}
+
+func (rs *remoteScript) runCmds(ctx context.Context, run *RunResult) error {
+	var firstErr error
 	for {
 		statment, err := rs.script.ReadString('\n')
 		if err == io.EOF {
@@ -251,47 +495,43 @@ func (rs *remoteScript) runCmds() error {
 			return err
 		}
 
-		if err := rs.runCmd(statment); err != nil {
-			return err
+		result := rs.runSession(ctx, statment, nil)
+		run.Results = append(run.Results, result)
+		if result.Err == nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = result.Err
+		}
+		if !rs.continueOnError || ctx.Err() != nil {
+			return firstErr
 		}
 	}
 
-	return nil
+	return firstErr
 }
 
-func (rs *remoteScript) runScript() error {
-	session, err := rs.client.NewSession()
-	if err != nil {
-		return err
-	}
-
-	session.Stdin = rs.script
-	session.Stdout = rs.stdout
-	session.Stderr = rs.stderr
-
-	if err := session.Shell(); err != nil {
-		return err
-	}
-	if err := session.Wait(); err != nil {
-		return err
-	}
-
-	return nil
+func (rs *remoteScript) runScript(ctx context.Context, run *RunResult) error {
+	result := rs.runSession(ctx, "", rs.script)
+	result.Cmd = "<script>"
+	run.Results = append(run.Results, result)
+	return result.Err
 }
 
-func (rs *remoteScript) runScriptFile() error {
+func (rs *remoteScript) runScriptFile(ctx context.Context, run *RunResult) error {
 	var buffer bytes.Buffer
 	file, err := os.Open(rs.scriptFile)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 	_, err = io.Copy(&buffer, file)
 	if err != nil {
 		return err
 	}
 
 	rs.script = &buffer
-	return rs.runScript()
+	return rs.runScript(ctx, run)
 }
 
 type remoteShell struct {
@@ -314,7 +554,7 @@ type TerminalConfig struct {
 // Terminal create a interactive shell on client.
 func (c *Client) Terminal(config *TerminalConfig) *remoteShell {
 	return &remoteShell{
-		client:         c.client,
+		client:         c.sshClient(),
 		terminalConfig: config,
 		requestPty:     true,
 	}
@@ -323,7 +563,7 @@ func (c *Client) Terminal(config *TerminalConfig) *remoteShell {
 // Shell create a noninteractive shell on client.
 func (c *Client) Shell() *remoteShell {
 	return &remoteShell{
-		client:     c.client,
+		client:     c.sshClient(),
 		requestPty: false,
 	}
 }
@@ -385,69 +625,223 @@ func (rs *remoteShell) Start() error {
 }
 
 func (s *Db) Connect(host string, port int, user, pwd, database string) error {
+	return s.ConnectWithConfig(NewConfig(host, port, user, pwd, database))
+}
+
+// ConnectWithConfig 按配置建立连接池
+func (s *Db) ConnectWithConfig(cfg Config) error {
+	if err := cfg.RegisterTLS(); err != nil {
+		xlog.ErrorP(err)
+		return err
+	}
+	// 日志中的时间参数按连接的时区展示，与驱动实际发送的值一致
+	loc := cfg.Loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	s.formatter = &sqlfmt.Formatter{Location: loc}
+	if s.Print && s.Log != nil && s.Log.SlowQuery != nil {
+		// EXPLAIN 使用不经过日志钩子的独立连接池
+		cfg := cfg
+		cfg.MaxOpenConns, cfg.MaxIdleConns = 1, 1
+		path, err := cfg.FormatDSN()
+		if err != nil {
+			xlog.ErrorP(err)
+			return err
+		}
+		explainDB, err := sql.Open("mysql", path)
+		if err != nil {
+			xlog.ErrorP(err)
+			return err
+		}
+		explainDB.SetMaxOpenConns(1)
+		slowCfg := *s.Log.SlowQuery
+		if slowCfg.Threshold <= 0 {
+			slowCfg.Threshold = s.Log.slowThreshold()
+		}
+		if slowCfg.Formatter == nil {
+			slowCfg.Formatter = s.formatter
+		}
+		s.analyzer = NewSlowQueryAnalyzer(explainDB, slowCfg)
+	}
 	var err error
-	diverName := "mysql"
-	if s.Print {
-		diverName = "mysql-with-logger"
-		SetSlowThreshold(300 * time.Millisecond)
-		// 注册驱动
-		RegisterWithLogging()
+	s.db, err = s.openPool(cfg)
+	if err != nil && s.analyzer != nil {
+		s.analyzer.Close()
+		s.analyzer = nil
+	}
+	return err
+}
+
+var sshNetworkSeq int64
+
+// ConnectViaSSH 通过ssh隧道连接mysql，适用于只能经跳板机访问的内网实例。
+// 隧道断开后会在下次建立连接时自动重连，Close 时隧道随连接池一起关闭
+func (s *Db) ConnectViaSSH(client *Client, cfg Config) error {
+	network := fmt.Sprintf("mysql+ssh-%d", atomic.AddInt64(&sshNetworkSeq, 1))
+	dialer := &ViaSSHDialer{client: client}
+	mysql.RegisterDialContext(network, dialer.Dial)
+	cfg.Net = network
+	if err := s.ConnectWithConfig(cfg); err != nil {
+		// mysql驱动不能注销network，替换为返回错误的dialer，避免注册表一直持有client
+		mysql.RegisterDialContext(network, func(ctx context.Context, addr string) (net.Conn, error) {
+			return nil, fmt.Errorf("db: network %s is no longer available", network)
+		})
+		return err
 	}
+	s.tunnel = client
+	return nil
+}
 
-	path := strings.Join([]string{user, ":", pwd, "@tcp(", host, ":", strconv.Itoa(port), ")/", database, "?charset=utf8mb4"}, "")
-	s.db, err = sql.Open(diverName, path)
+// openPool 打开连接池，Print 为true时连接会经过该Db的日志钩子
+func (s *Db) openPool(cfg Config) (*sql.DB, error) {
+	if err := cfg.RegisterTLS(); err != nil {
+		xlog.ErrorP(err)
+		return nil, err
+	}
+	path, err := cfg.FormatDSN()
 	if err != nil {
 		xlog.ErrorP(err)
-		return err
+		return nil, err
+	}
+	var pool *sql.DB
+	if s.Print {
+		var connector driver.Connector
+		connector, err = (&mysql.MySQLDriver{}).OpenConnector(path)
+		if err == nil {
+			pool = sql.OpenDB(&loggingConnector{Connector: connector, hooks: s.dbHooks})
+		}
+	} else {
+		pool, err = sql.Open("mysql", path)
+	}
+	if err != nil {
+		xlog.ErrorP(err)
+		return nil, err
 	}
 
-	s.db.SetMaxOpenConns(200)
-	s.db.SetMaxIdleConns(100)
-	return nil
+	pool.SetMaxOpenConns(cfg.MaxOpenConns)
+	pool.SetMaxIdleConns(cfg.MaxIdleConns)
+	pool.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	pool.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return pool, nil
+}
+
+// dbHooks 该Db的日志钩子加上通过 AddHook 注册的全局钩子
+func (s *Db) dbHooks() []Hook {
+	s.logOnce.Do(func() {
+		cfg := s.Log
+		if cfg == nil {
+			cfg = &LogConfig{}
+		}
+		s.logHooks = append([]Hook{cfg.hook(s.formatter)}, cfg.Hooks...)
+		if s.analyzer != nil {
+			s.logHooks = append(s.logHooks, s.analyzer)
+		}
+	})
+	return append(s.logHooks[:len(s.logHooks):len(s.logHooks)], currentHooks()...)
+}
+
+// NewWithDB 使用已有的连接池创建Db，便于接入其他驱动或测试用的假驱动
+func NewWithDB(pool *sql.DB) *Db {
+	return &Db{db: pool}
+}
+
+// GetDb 返回主库连接池
+func (s *Db) GetDb() *sql.DB {
+	return s.db
+}
+
+// Close 关闭主库及所有从库连接池
+func (s *Db) Close() error {
+	if s.replicas != nil {
+		s.replicas.close()
+	}
+	if s.analyzer != nil {
+		s.analyzer.Close()
+	}
+	var err error
+	if s.db != nil {
+		err = s.db.Close()
+	}
+	if s.tunnel != nil {
+		if e := s.tunnel.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
 func (s *Db) Query(sql string, args ...interface{}) (rows *sql.Rows, err error) {
-	t := time.Now()
-	rows, err = s.db.Query(sql, args...)
-	if s.Print {
-		xlog.DB(false, time.Now().Sub(t), 0, sql, args...)
+	return s.QueryContext(context.Background(), sql, args...)
+}
+
+func (s *Db) QueryRow(sql string, args ...interface{}) (result *sql.Row) {
+	return s.QueryRowContext(context.Background(), sql, args...)
+}
+
+func (s *Db) Exec(sql string, args ...interface{}) (result sql.Result, err error) {
+	return s.ExecContext(context.Background(), sql, args...)
+}
+
+// QueryContext 带context的查询，ctx取消或超时后会中断mysql上的查询。
+// 配置了从库时查询会路由到从库，可用 WithPrimary 强制走主库。
+// ctx来自本Db开启的事务的 Tx.Context() 时在该事务中执行，与 WithTransactionContext 一致
+func (s *Db) QueryContext(ctx context.Context, sql string, args ...interface{}) (rows *sql.Rows, err error) {
+	if tx := txFromContext(ctx, s); tx != nil {
+		return tx.QueryContext(ctx, sql, args...)
 	}
+	rows, err = s.reader(ctx).QueryContext(ctx, sql, args...)
 	if err != nil {
 		xlog.ErrorP(err)
 	}
 	return rows, err
 }
 
-func (s *Db) QueryRow(sql string, args ...interface{}) (result *sql.Row) {
-	t := time.Now()
-	result = s.db.QueryRow(sql, args...)
-	if s.Print {
-		xlog.DB(false, time.Now().Sub(t), 0, sql, args...)
+// QueryRowContext 带context的单行查询，事务规则同 QueryContext
+func (s *Db) QueryRowContext(ctx context.Context, sql string, args ...interface{}) (result *sql.Row) {
+	if tx := txFromContext(ctx, s); tx != nil {
+		return tx.QueryRowContext(ctx, sql, args...)
 	}
-	return result
+	return s.reader(ctx).QueryRowContext(ctx, sql, args...)
 }
 
-func (s *Db) Exec(sql string, args ...interface{}) (result sql.Result, err error) {
-	t := time.Now()
-	result, err = s.db.Exec(sql, args...)
-	var affected int64
-	if err == nil {
-		affected, _ = result.RowsAffected()
-	} else {
-		xlog.ErrorP(err)
+// ExecContext 带context的执行，事务规则同 QueryContext
+func (s *Db) ExecContext(ctx context.Context, sql string, args ...interface{}) (result sql.Result, err error) {
+	if tx := txFromContext(ctx, s); tx != nil {
+		return tx.ExecContext(ctx, sql, args...)
 	}
-	if s.Print {
-		xlog.DB(true, time.Now().Sub(t), affected, sql, args...)
+	result, err = s.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		xlog.ErrorP(err)
 	}
 	return result, err
 }
 
 func (s *Db) WithTransaction(f func(tx *Tx) error) error {
+	return s.WithTransactionContext(context.Background(), nil, f)
+}
+
+// WithTransactionContext 带context开启事务，opts可指定隔离级别和只读，为nil时使用默认值。
+// 如果ctx来自本Db外层事务的 Tx.Context()，则不会开启新事务，而是在外层事务中创建SAVEPOINT
+func (s *Db) WithTransactionContext(ctx context.Context, opts *sql.TxOptions, f func(tx *Tx) error) error {
+	if parent := txFromContext(ctx, s); parent != nil {
+		return parent.WithTransactionContext(ctx, f)
+	}
+	if s.Retry != nil {
+		return s.WithTransactionRetry(ctx, opts, s.Retry, f)
+	}
+	return s.withTransaction(ctx, opts, f)
+}
+
+func (s *Db) withTransaction(ctx context.Context, opts *sql.TxOptions, f func(tx *Tx) error) error {
 	var t = &Tx{
-		Print: s.Print,
+		db:         s,
+		Print:      s.Print,
+		savepoints: new(int32),
 	}
+	t.ctx = context.WithValue(ctx, txKey{}, t)
 	var err error
-	t.tx, err = s.db.Begin()
+	t.tx, err = s.db.BeginTx(ctx, opts)
 	if err != nil {
 		xlog.ErrorP(err)
 		return err
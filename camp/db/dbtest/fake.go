@@ -0,0 +1,370 @@
+// Package dbtest 提供基于假驱动的 db.Db，用于在没有mysql的情况下测试仓储代码：
+//
+//	d, fake := dbtest.New()
+//	fake.ExpectBegin()
+//	fake.ExpectExec(`UPDATE user SET name`).WithArgs("bob", 1).WillReturnResult(0, 1)
+//	fake.ExpectCommit()
+//	err := repo.Rename(d, 1, "bob")
+//	if err := fake.ExpectationsWereMet(); err != nil {
+//		t.Fatal(err)
+//	}
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mufe/golang-base/camp/db"
+)
+
+type kind string
+
+const (
+	kindQuery    kind = "query"
+	kindExec     kind = "exec"
+	kindBegin    kind = "begin"
+	kindCommit   kind = "commit"
+	kindRollback kind = "rollback"
+)
+
+// Expectation 一条预期的数据库调用
+type Expectation struct {
+	kind    kind
+	pattern *regexp.Regexp
+	args    []driver.Value
+	anyArgs bool
+
+	columns []string
+	rows    [][]driver.Value
+	result  driver.Result
+	err     error
+
+	triggered bool
+}
+
+// WithArgs 要求参数完全一致，未调用时不检查参数
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.anyArgs = false
+	e.args = make([]driver.Value, len(args))
+	for i, arg := range args {
+		v, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		if err != nil {
+			panic(fmt.Sprintf("dbtest: invalid arg %d: %v", i, err))
+		}
+		e.args[i] = v
+	}
+	return e
+}
+
+// WillReturnRows 设置查询返回的列和行，值按 driver.DefaultParameterConverter 转换，int 等类型可直接使用
+func (e *Expectation) WillReturnRows(columns []string, rows ...[]interface{}) *Expectation {
+	e.columns = columns
+	e.rows = make([][]driver.Value, len(rows))
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			panic(fmt.Sprintf("dbtest: row %d has %d values, want %d", i, len(row), len(columns)))
+		}
+		e.rows[i] = make([]driver.Value, len(row))
+		for j, v := range row {
+			value, err := driver.DefaultParameterConverter.ConvertValue(v)
+			if err != nil {
+				panic(fmt.Sprintf("dbtest: invalid value in row %d column %q: %v", i, columns[j], err))
+			}
+			e.rows[i][j] = value
+		}
+	}
+	return e
+}
+
+// WillReturnResult 设置执行的结果
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.result = result{lastInsertID: lastInsertID, rowsAffected: rowsAffected}
+	return e
+}
+
+// WillReturnError 让该调用返回err
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) String() string {
+	if e.pattern == nil {
+		return string(e.kind)
+	}
+	return fmt.Sprintf("%s matching %q", e.kind, e.pattern.String())
+}
+
+// Fake 按顺序匹配预期调用的假驱动
+type Fake struct {
+	mutex        sync.Mutex
+	expectations []*Expectation
+	// errs 记录不符合预期的调用
+	errs []error
+}
+
+// New 返回使用假驱动的 db.Db 以及用于设置预期的 Fake
+func New() (*db.Db, *Fake) {
+	f := &Fake{}
+	return db.NewWithDB(sql.OpenDB(&connector{fake: f})), f
+}
+
+// ExpectQuery 预期一次查询，pattern为正则表达式
+func (f *Fake) ExpectQuery(pattern string) *Expectation {
+	return f.expect(kindQuery, pattern)
+}
+
+// ExpectExec 预期一次执行，pattern为正则表达式
+func (f *Fake) ExpectExec(pattern string) *Expectation {
+	return f.expect(kindExec, pattern)
+}
+
+// ExpectBegin 预期开启事务
+func (f *Fake) ExpectBegin() *Expectation {
+	return f.expect(kindBegin, "")
+}
+
+// ExpectCommit 预期提交事务
+func (f *Fake) ExpectCommit() *Expectation {
+	return f.expect(kindCommit, "")
+}
+
+// ExpectRollback 预期回滚事务
+func (f *Fake) ExpectRollback() *Expectation {
+	return f.expect(kindRollback, "")
+}
+
+func (f *Fake) expect(k kind, pattern string) *Expectation {
+	e := &Expectation{kind: k, anyArgs: true}
+	if pattern != "" {
+		e.pattern = regexp.MustCompile(pattern)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.expectations = append(f.expectations, e)
+	return e
+}
+
+// ExpectationsWereMet 所有预期都已触发且没有意外的调用时返回nil
+func (f *Fake) ExpectationsWereMet() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var msgs []string
+	for _, err := range f.errs {
+		msgs = append(msgs, err.Error())
+	}
+	for _, e := range f.expectations {
+		if !e.triggered {
+			msgs = append(msgs, "dbtest: expected "+e.String()+" was not called")
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// match 找到下一条未触发的预期并检查是否匹配
+func (f *Fake) match(k kind, query string, args []driver.NamedValue) (*Expectation, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var next *Expectation
+	for _, e := range f.expectations {
+		if !e.triggered {
+			next = e
+			break
+		}
+	}
+	err := func() error {
+		call := string(k)
+		if query != "" {
+			call += fmt.Sprintf(" %q", query)
+		}
+		if next == nil {
+			return fmt.Errorf("dbtest: unexpected %s, no more expectations", call)
+		}
+		if next.kind != k {
+			return fmt.Errorf("dbtest: unexpected %s, expected %s", call, next)
+		}
+		if next.pattern != nil && !next.pattern.MatchString(query) {
+			return fmt.Errorf("dbtest: unexpected %s, expected %s", call, next)
+		}
+		if !next.anyArgs && !argsEqual(next.args, args) {
+			return fmt.Errorf("dbtest: %s called with args %v, expected %v", call, namedValues(args), next.args)
+		}
+		return nil
+	}()
+	if err != nil {
+		f.errs = append(f.errs, err)
+		return nil, err
+	}
+	next.triggered = true
+	return next, next.err
+}
+
+func argsEqual(want []driver.Value, got []driver.NamedValue) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if !reflect.DeepEqual(want[i], got[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+type connector struct {
+	fake *Fake
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{fake: c.fake}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("dbtest: use dbtest.New")
+}
+
+type conn struct {
+	fake *Fake
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.fake.match(kindBegin, "", nil); err != nil {
+		return nil, err
+	}
+	return &tx{fake: c.fake}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.fake.match(kindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: e.columns, rows: e.rows}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.fake.match(kindExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.result == nil {
+		return result{}, nil
+	}
+	return e.result, nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, toNamed(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, toNamed(args))
+}
+
+func toNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+type tx struct {
+	fake *Fake
+}
+
+func (t *tx) Commit() error {
+	_, err := t.fake.match(kindCommit, "", nil)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	_, err := t.fake.match(kindRollback, "", nil)
+	return err
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type rows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
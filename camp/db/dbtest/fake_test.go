@@ -0,0 +1,165 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mufe/golang-base/camp/db"
+)
+
+type user struct {
+	ID   int64
+	Name string
+}
+
+func TestQuery(t *testing.T) {
+	d, fake := New()
+	fake.ExpectQuery(`SELECT id, name FROM user WHERE id = \?`).WithArgs(1).
+		WillReturnRows([]string{"id", "name"}, []interface{}{1, "alice"})
+
+	var u user
+	if err := d.Get(context.Background(), &u, "SELECT id, name FROM user WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	if u != (user{ID: 1, Name: "alice"}) {
+		t.Errorf("got %+v", u)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransactionCommit(t *testing.T) {
+	d, fake := New()
+	fake.ExpectBegin()
+	fake.ExpectExec(`UPDATE user SET name`).WithArgs("bob", 1).WillReturnResult(0, 1)
+	fake.ExpectCommit()
+
+	err := d.WithTransaction(func(tx *db.Tx) error {
+		result, err := tx.Exec("UPDATE user SET name = ? WHERE id = ?", "bob", 1)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n != 1 {
+			t.Errorf("RowsAffected = %d, want 1", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	d, fake := New()
+	errDup := errors.New("duplicate")
+	fake.ExpectBegin()
+	fake.ExpectExec(`INSERT INTO user`).WillReturnError(errDup)
+	fake.ExpectRollback()
+
+	err := d.WithTransaction(func(tx *db.Tx) error {
+		_, err := tx.Exec("INSERT INTO user (name) VALUES (?)", "bob")
+		return err
+	})
+	if !errors.Is(err, errDup) {
+		t.Fatalf("err = %v, want %v", err, errDup)
+	}
+	if err := fake.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestContextTransactionOtherDb(t *testing.T) {
+	d1, fake1 := New()
+	d2, fake2 := New()
+	fake1.ExpectBegin()
+	fake1.ExpectExec(`UPDATE a`).WillReturnResult(0, 1)
+	fake1.ExpectCommit()
+	fake2.ExpectExec(`UPDATE b`).WillReturnResult(0, 1)
+
+	// d1的事务ctx传给d2时，d2不能在d1的事务中执行
+	err := d1.WithTransaction(func(tx *db.Tx) error {
+		if _, err := d1.ExecContext(tx.Context(), "UPDATE a SET x = 1"); err != nil {
+			return err
+		}
+		_, err := d2.ExecContext(tx.Context(), "UPDATE b SET x = 1")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fake1.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+	if err := fake2.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		expect func(f *Fake)
+		run    func(d *db.Db) error
+		want   string
+	}{
+		{
+			name:   "wrong query",
+			expect: func(f *Fake) { f.ExpectExec(`DELETE FROM user`) },
+			run: func(d *db.Db) error {
+				_, err := d.Exec("UPDATE user SET name = ''")
+				return err
+			},
+			want: "expected exec matching",
+		},
+		{
+			name:   "wrong kind",
+			expect: func(f *Fake) { f.ExpectQuery(`SELECT`) },
+			run: func(d *db.Db) error {
+				_, err := d.Exec("SELECT 1")
+				return err
+			},
+			want: "unexpected exec",
+		},
+		{
+			name:   "wrong args",
+			expect: func(f *Fake) { f.ExpectExec(`DELETE FROM user`).WithArgs(1) },
+			run: func(d *db.Db) error {
+				_, err := d.Exec("DELETE FROM user WHERE id = ?", 2)
+				return err
+			},
+			want: "called with args [2], expected [1]",
+		},
+		{
+			name:   "no more expectations",
+			expect: func(f *Fake) {},
+			run: func(d *db.Db) error {
+				_, err := d.Exec("DELETE FROM user")
+				return err
+			},
+			want: "no more expectations",
+		},
+		{
+			name:   "not called",
+			expect: func(f *Fake) { f.ExpectBegin() },
+			run:    func(d *db.Db) error { return nil },
+			want:   "expected begin was not called",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, fake := New()
+			tt.expect(fake)
+			tt.run(d)
+			err := fake.ExpectationsWereMet()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ExpectationsWereMet() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mufe/golang-base/camp/xlog"
+)
+
+// Forwarder relays connections accepted on a listener to a target address.
+type Forwarder struct {
+	listener net.Listener
+	dial     func() (net.Conn, error)
+	target   string
+
+	active int64
+	total  int64
+
+	mutex  sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// LocalForward listens on localAddr and forwards every connection to remoteAddr through
+// the ssh server, like ssh -L. Use "127.0.0.1:0" to pick a free port and read it from Addr.
+func (c *Client) LocalForward(localAddr, remoteAddr string) (*Forwarder, error) {
+	listener, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, err
+	}
+	return newForwarder(listener, remoteAddr, func() (net.Conn, error) {
+		return c.sshClient().Dial("tcp", remoteAddr)
+	}), nil
+}
+
+// RemoteForward asks the ssh server to listen on remoteAddr and forwards every connection
+// to localAddr on this side, like ssh -R.
+func (c *Client) RemoteForward(remoteAddr, localAddr string) (*Forwarder, error) {
+	listener, err := c.sshClient().Listen("tcp", remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	return newForwarder(listener, localAddr, func() (net.Conn, error) {
+		return net.Dial("tcp", localAddr)
+	}), nil
+}
+
+func newForwarder(listener net.Listener, target string, dial func() (net.Conn, error)) *Forwarder {
+	f := &Forwarder{
+		listener: listener,
+		dial:     dial,
+		target:   target,
+		conns:    map[net.Conn]struct{}{},
+	}
+	f.wg.Add(1)
+	go f.serve()
+	return f
+}
+
+// Addr returns the listening address.
+func (f *Forwarder) Addr() net.Addr {
+	return f.listener.Addr()
+}
+
+// ActiveConns returns the number of connections currently being forwarded.
+func (f *Forwarder) ActiveConns() int64 {
+	return atomic.LoadInt64(&f.active)
+}
+
+// TotalConns returns the number of connections forwarded since the start.
+func (f *Forwarder) TotalConns() int64 {
+	return atomic.LoadInt64(&f.total)
+}
+
+// Close stops listening and closes all forwarded connections.
+func (f *Forwarder) Close() error {
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return nil
+	}
+	f.closed = true
+	err := f.listener.Close()
+	for conn := range f.conns {
+		conn.Close()
+	}
+	f.mutex.Unlock()
+	f.wg.Wait()
+	return err
+}
+
+func (f *Forwarder) serve() {
+	defer f.wg.Done()
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			f.mutex.Lock()
+			closed := f.closed
+			f.mutex.Unlock()
+			if !closed {
+				xlog.ErrorP(err)
+			}
+			return
+		}
+		f.wg.Add(1)
+		go f.handle(conn)
+	}
+}
+
+func (f *Forwarder) handle(conn net.Conn) {
+	defer f.wg.Done()
+	target, err := f.dial()
+	if err != nil {
+		xlog.Errorf("forward to %s: %v", f.target, err)
+		conn.Close()
+		return
+	}
+	if !f.track(conn, target) {
+		conn.Close()
+		target.Close()
+		return
+	}
+	atomic.AddInt64(&f.active, 1)
+	atomic.AddInt64(&f.total, 1)
+	defer func() {
+		atomic.AddInt64(&f.active, -1)
+		f.untrack(conn, target)
+	}()
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go pipe(target, conn)
+	go pipe(conn, target)
+	// either side finishing ends the forwarded connection
+	<-done
+	conn.Close()
+	target.Close()
+	<-done
+}
+
+func (f *Forwarder) track(conns ...net.Conn) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return false
+	}
+	for _, conn := range conns {
+		f.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (f *Forwarder) untrack(conns ...net.Conn) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, conn := range conns {
+		delete(f.conns, conn)
+	}
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mufe/golang-base/camp/sqlfmt"
+)
+
+// ErrEmptyIn In 的切片参数为空，此时 IN () 在mysql中是语法错误，需要调用方自行处理
+var ErrEmptyIn = errors.New("db: empty slice passed to In")
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// In 将query中对应切片参数的 ? 展开为 ?, ?, ...，返回新的query和展开后的参数，
+// 可直接传给 Db、Tx 的查询方法：
+//
+//	query, args, err := db.In("SELECT * FROM user WHERE id IN (?) AND status = ?", ids, 1)
+//
+// []byte 和实现了 driver.Valuer 的类型不展开；任一切片为空时返回 ErrEmptyIn
+func In(query string, args ...interface{}) (string, []interface{}, error) {
+	expand := make([]int, len(args))
+	var needExpand bool
+	for i, arg := range args {
+		n, ok := sliceLen(arg)
+		if !ok {
+			expand[i] = -1
+			continue
+		}
+		if n == 0 {
+			return "", nil, ErrEmptyIn
+		}
+		expand[i] = n
+		needExpand = true
+	}
+	if !needExpand {
+		return query, args, nil
+	}
+
+	positions := placeholderPositions(query)
+	if len(positions) != len(args) {
+		return "", nil, fmt.Errorf("db: number of placeholders (%d) does not match number of args (%d)", len(positions), len(args))
+	}
+	var (
+		buf     strings.Builder
+		newArgs = make([]interface{}, 0, len(args))
+		last    int
+	)
+	for i, pos := range positions {
+		buf.WriteString(query[last:pos])
+		last = pos + 1
+		if expand[i] < 0 {
+			buf.WriteByte('?')
+			newArgs = append(newArgs, args[i])
+			continue
+		}
+		v := reflect.ValueOf(args[i])
+		for j := 0; j < expand[i]; j++ {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteByte('?')
+			newArgs = append(newArgs, v.Index(j).Interface())
+		}
+	}
+	buf.WriteString(query[last:])
+	return buf.String(), newArgs, nil
+}
+
+// sliceLen 参数是否需要展开以及展开后的长度
+func sliceLen(arg interface{}) (int, bool) {
+	if arg == nil {
+		return 0, false
+	}
+	t := reflect.TypeOf(arg)
+	if t.Implements(valuerType) {
+		return 0, false
+	}
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return 0, false
+	}
+	if t.Elem().Kind() == reflect.Uint8 {
+		return 0, false
+	}
+	return reflect.ValueOf(arg).Len(), true
+}
+
+// placeholderPositions 返回query中 ? 占位符的下标，跳过字符串、标识符和注释中的 ?
+func placeholderPositions(query string) []int {
+	var positions []int
+	for _, t := range sqlfmt.Tokenize(query) {
+		if t.Kind == sqlfmt.Placeholder {
+			positions = append(positions, t.Pos)
+		}
+	}
+	return positions
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIn(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		args      []interface{}
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   error
+	}{
+		{
+			name:      "no slice",
+			query:     "SELECT * FROM t WHERE a = ?",
+			args:      []interface{}{1},
+			wantQuery: "SELECT * FROM t WHERE a = ?",
+			wantArgs:  []interface{}{1},
+		},
+		{
+			name:      "slice and scalar",
+			query:     "SELECT * FROM t WHERE id IN (?) AND status = ?",
+			args:      []interface{}{[]int{1, 2, 3}, 1},
+			wantQuery: "SELECT * FROM t WHERE id IN (?, ?, ?) AND status = ?",
+			wantArgs:  []interface{}{1, 2, 3, 1},
+		},
+		{
+			name:      "array",
+			query:     "a IN (?)",
+			args:      []interface{}{[2]string{"x", "y"}},
+			wantQuery: "a IN (?, ?)",
+			wantArgs:  []interface{}{"x", "y"},
+		},
+		{
+			name:      "placeholder inside literal",
+			query:     "SELECT '?' FROM t WHERE id IN (?)",
+			args:      []interface{}{[]int{1, 2}},
+			wantQuery: "SELECT '?' FROM t WHERE id IN (?, ?)",
+			wantArgs:  []interface{}{1, 2},
+		},
+		{
+			name:      "placeholder inside identifier and comments",
+			query:     "SELECT `a?` FROM t /* ? */ WHERE id IN (?) -- ?\nAND b = ? # ?",
+			args:      []interface{}{[]int64{7, 8}, "x"},
+			wantQuery: "SELECT `a?` FROM t /* ? */ WHERE id IN (?, ?) -- ?\nAND b = ? # ?",
+			wantArgs:  []interface{}{int64(7), int64(8), "x"},
+		},
+		{
+			name:      "bytes are not expanded",
+			query:     "a = ? AND b IN (?)",
+			args:      []interface{}{[]byte("raw"), []string{"x"}},
+			wantQuery: "a = ? AND b IN (?)",
+			wantArgs:  []interface{}{[]byte("raw"), "x"},
+		},
+		{
+			name:      "valuer is not expanded",
+			query:     "a = ? AND b IN (?)",
+			args:      []interface{}{sql.NullString{String: "v", Valid: true}, []int{1, 2}},
+			wantQuery: "a = ? AND b IN (?, ?)",
+			wantArgs:  []interface{}{sql.NullString{String: "v", Valid: true}, 1, 2},
+		},
+		{
+			name:      "nil arg",
+			query:     "a = ? AND b IN (?)",
+			args:      []interface{}{nil, []int{1}},
+			wantQuery: "a = ? AND b IN (?)",
+			wantArgs:  []interface{}{nil, 1},
+		},
+		{
+			name:    "empty slice",
+			query:   "a IN (?)",
+			args:    []interface{}{[]int{}},
+			wantErr: ErrEmptyIn,
+		},
+		{
+			name:    "nil slice",
+			query:   "a IN (?)",
+			args:    []interface{}{[]string(nil)},
+			wantErr: ErrEmptyIn,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := In(tt.query, tt.args...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestInPlaceholderMismatch(t *testing.T) {
+	// 字面量中的 ? 不计入占位符
+	_, _, err := In("SELECT '?' FROM t WHERE id IN (?)", []int{1}, 2)
+	if err == nil {
+		t.Fatal("want error for mismatched placeholder count")
+	}
+}
@@ -1,44 +1,19 @@
 package db
 
 import (
-	"fmt"
-	"strings"
-	"time"
+	"github.com/mufe/golang-base/camp/sqlfmt"
 )
 
+// InterpolateSQL 将参数嵌入语句用于日志展示，字符串和注释中的 ? 不会被替换。
+// 时间参数按UTC展示，需要与连接时区一致时使用 Db.Formatter
 func InterpolateSQL(query string, args ...interface{}) string {
-	for _, arg := range args {
-		query = strings.Replace(query, "?", formatArg(arg), 1)
-	}
-	return query
+	return sqlfmt.Interpolate(query, args...)
 }
 
-func formatArg(arg interface{}) string {
-	switch v := arg.(type) {
-	case nil:
-		return "NULL"
-	case string:
-		return "'" + escapeString(v) + "'"
-	case []byte:
-		return "'" + escapeString(string(v)) + "'"
-	case time.Time:
-		return "'" + v.Format("2006-01-02 15:04:05") + "'"
-	case bool:
-		if v {
-			return "1"
-		}
-		return "0"
-	case int, int8, int16, int32, int64:
-		return fmt.Sprintf("%d", v)
-	case uint, uint8, uint16, uint32, uint64:
-		return fmt.Sprintf("%d", v)
-	case float32, float64:
-		return fmt.Sprintf("%f", v)
-	default:
-		return fmt.Sprintf("'%v'", escapeString(fmt.Sprint(v)))
+// Formatter 返回按连接的 Config.Loc 嵌入参数的格式，未连接时为 sqlfmt.Default
+func (s *Db) Formatter() *sqlfmt.Formatter {
+	if s.formatter == nil {
+		return sqlfmt.Default
 	}
-}
-
-func escapeString(s string) string {
-	return strings.ReplaceAll(s, "'", "''")
+	return s.formatter
 }
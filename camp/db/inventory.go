@@ -0,0 +1,233 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Host is one machine of an Inventory. Empty fields fall back to the inventory defaults.
+type Host struct {
+	Name string `json:"name" yaml:"name"`
+	// Addr is host or host:port, the port defaults to 22.
+	Addr       string `json:"addr" yaml:"addr"`
+	User       string `json:"user" yaml:"user"`
+	Password   string `json:"password" yaml:"password"`
+	KeyFile    string `json:"key_file" yaml:"key_file"`
+	Passphrase string `json:"passphrase" yaml:"passphrase"`
+	// Agent uses the keys of the ssh-agent on SSH_AUTH_SOCK.
+	Agent bool `json:"agent" yaml:"agent"`
+}
+
+// Inventory is a list of hosts with shared defaults, loaded from YAML or JSON:
+//
+//	user: deploy
+//	key_file: ~/.ssh/id_rsa
+//	hosts:
+//	  - name: web-1
+//	    addr: 10.0.0.1
+//	  - name: web-2
+//	    addr: 10.0.0.2:2222
+//	    password: secret
+type Inventory struct {
+	User       string `json:"user" yaml:"user"`
+	Password   string `json:"password" yaml:"password"`
+	KeyFile    string `json:"key_file" yaml:"key_file"`
+	Passphrase string `json:"passphrase" yaml:"passphrase"`
+	Agent      bool   `json:"agent" yaml:"agent"`
+	Hosts      []Host `json:"hosts" yaml:"hosts"`
+}
+
+// LoadInventory reads an inventory file, the format is chosen by the .json, .yaml or .yml extension.
+func LoadInventory(file string) (*Inventory, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	inv := &Inventory{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(data, inv)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, inv)
+	default:
+		return nil, fmt.Errorf("inventory: unsupported file type %s", file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// host returns h with the inventory defaults applied.
+func (inv *Inventory) host(h Host) Host {
+	if h.User == "" {
+		h.User = inv.User
+	}
+	if h.Password == "" && h.KeyFile == "" && !h.Agent {
+		h.Agent = inv.Agent
+		h.Password = inv.Password
+		h.KeyFile = inv.KeyFile
+		h.Passphrase = inv.Passphrase
+	}
+	if h.Name == "" {
+		h.Name = h.Addr
+	}
+	if _, _, err := net.SplitHostPort(h.Addr); err != nil {
+		h.Addr = net.JoinHostPort(h.Addr, "22")
+	}
+	return h
+}
+
+// Dial connects to the host with its configured auth method.
+func (h Host) Dial(opts ...DialOption) (*Client, error) {
+	keyFile := h.KeyFile
+	if strings.HasPrefix(keyFile, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			keyFile = filepath.Join(home, keyFile[2:])
+		}
+	}
+	switch {
+	case h.Agent:
+		return DialWithAgent(h.Addr, h.User, opts...)
+	case keyFile != "" && h.Passphrase != "":
+		return DialWithKeyWithPassphrase(h.Addr, h.User, keyFile, h.Passphrase, opts...)
+	case keyFile != "":
+		return DialWithKey(h.Addr, h.User, keyFile, opts...)
+	case h.Password != "":
+		return DialWithPasswd(h.Addr, h.User, h.Password, opts...)
+	default:
+		return nil, fmt.Errorf("inventory: no auth method for host %s", h.Name)
+	}
+}
+
+// HostResult is the outcome of running a script on one host.
+type HostResult struct {
+	Host   Host
+	Result *RunResult
+	// Err is the dial error or the first command failure.
+	Err error
+}
+
+// Runner executes the same script on every host of an inventory.
+type Runner struct {
+	// Concurrency is the maximum number of hosts handled at once, 0 means 10.
+	Concurrency int
+	DialOptions []DialOption
+	// Stdout and Stderr receive the streamed output of all hosts, each line prefixed with [host name].
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Run dials every host and runs the script returned by build on it. Results are in inventory order.
+func (r *Runner) Run(ctx context.Context, inv *Inventory, build func(c *Client) *remoteScript) []HostResult {
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	var (
+		results  = make([]HostResult, len(inv.Hosts))
+		sem      = make(chan struct{}, concurrency)
+		wg       sync.WaitGroup
+		outMutex sync.Mutex
+	)
+	for i, h := range inv.Hosts {
+		h = inv.host(h)
+		results[i].Host = h
+		wg.Add(1)
+		go func(i int, h Host) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			results[i].Result, results[i].Err = r.runHost(ctx, h, build, &outMutex)
+		}(i, h)
+	}
+	wg.Wait()
+	return results
+}
+
+func (r *Runner) runHost(ctx context.Context, h Host, build func(c *Client) *remoteScript, outMutex *sync.Mutex) (*RunResult, error) {
+	client, err := h.Dial(r.DialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	script := build(client)
+	if script == nil {
+		return nil, errors.New("inventory: nil script")
+	}
+	prefix := "[" + h.Name + "] "
+	var stdout, stderr *prefixWriter
+	if r.Stdout != nil {
+		stdout = &prefixWriter{w: r.Stdout, prefix: prefix, mutex: outMutex}
+		defer stdout.Flush()
+	}
+	if r.Stderr != nil {
+		stderr = &prefixWriter{w: r.Stderr, prefix: prefix, mutex: outMutex}
+		defer stderr.Flush()
+	}
+	script.SetStdio(writerOrNil(stdout), writerOrNil(stderr))
+	return script.RunContext(ctx)
+}
+
+func writerOrNil(w *prefixWriter) io.Writer {
+	if w == nil {
+		return nil
+	}
+	return w
+}
+
+// prefixWriter writes complete lines prefixed with a host name. Writers of different hosts
+// share a mutex so lines are not interleaved.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mutex  *sync.Mutex
+	buf    bytes.Buffer
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf.Write(b)
+	for {
+		line, err := p.buf.ReadBytes('\n')
+		if err != nil {
+			// keep the incomplete line for the next write
+			p.buf.Write(line)
+			return len(b), nil
+		}
+		if err := p.writeLine(line); err != nil {
+			return len(b), err
+		}
+	}
+}
+
+// Flush writes the remaining incomplete line.
+func (p *prefixWriter) Flush() {
+	if p.buf.Len() > 0 {
+		p.writeLine(append(p.buf.Bytes(), '\n'))
+		p.buf.Reset()
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	_, err := io.WriteString(p.w, p.prefix+string(line))
+	return err
+}
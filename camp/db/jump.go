@@ -0,0 +1,98 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AgentAuth returns an auth method backed by the ssh-agent listening on SSH_AUTH_SOCK.
+// The returned connection to the agent must stay open while the method is in use.
+func AgentAuth() (ssh.AuthMethod, net.Conn, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, nil, errors.New("ssh: SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), conn, nil
+}
+
+// DialWithAgent starts a client connection to the given SSH server with the keys of the ssh-agent.
+func DialWithAgent(addr, user string, opts ...DialOption) (*Client, error) {
+	auth, conn, err := AgentAuth()
+	if err != nil {
+		return nil, err
+	}
+	config, err := NewClientConfig(user, []ssh.AuthMethod{auth}, opts...)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client, err := Dial("tcp", addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client.closers = append(client.closers, conn)
+	return client, nil
+}
+
+// Hop is one ssh server on the way to the target host.
+type Hop struct {
+	Addr string
+	// Config is required, use NewClientConfig to verify the hop against known_hosts.
+	Config *ssh.ClientConfig
+}
+
+// Jump connects to addr through c, like ssh -J. Closing the returned client does not close c.
+func (c *Client) Jump(addr string, config *ssh.ClientConfig) (*Client, error) {
+	if config == nil {
+		return nil, fmt.Errorf("ssh: nil config for %s", addr)
+	}
+	return newClient(func() (*ssh.Client, error) {
+		conn, err := c.sshClient().Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return ssh.NewClient(clientConn, chans, reqs), nil
+	})
+}
+
+// DialChain connects to the last hop through all the previous ones, the first hop is dialed
+// directly. Closing the returned client also closes the jump host connections.
+func DialChain(hops ...Hop) (*Client, error) {
+	if len(hops) == 0 {
+		return nil, errors.New("ssh: no hops")
+	}
+	for i, hop := range hops {
+		if hop.Config == nil {
+			return nil, fmt.Errorf("ssh: nil config for hop %d (%s)", i, hop.Addr)
+		}
+	}
+	client, err := Dial("tcp", hops[0].Addr, hops[0].Config)
+	if err != nil {
+		return nil, err
+	}
+	for _, hop := range hops[1:] {
+		next, err := client.Jump(hop.Addr, hop.Config)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		next.closers = append(next.closers, client)
+		client = next
+	}
+	return client, nil
+}
@@ -4,60 +4,247 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"io"
 	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/mufe/golang-base/camp/sqlfmt"
+	"github.com/mufe/golang-base/camp/xlog"
 	"github.com/ngrok/sqlmw"
 )
 
-// Logger 实现 sqlmw.Interceptor 接口，拦截 Exec、Query、Prepare 等
-type Logger struct {
-	sqlmw.NullInterceptor
+// 操作类型
+const (
+	OpExec      = "EXEC"
+	OpQuery     = "QUERY"
+	OpStmtExec  = "STMT-EXEC"
+	OpStmtQuery = "STMT-QUERY"
+	OpBegin     = "TX BEGIN"
+	OpCommit    = "TX COMMIT"
+	OpRollback  = "TX ROLLBACK"
+)
+
+// QueryEvent 一次数据库操作的信息，Before 时只有 Op、Query、Args、Start、TxID 有值
+type QueryEvent struct {
+	Op    string
+	Query string
+	Args  []interface{}
+	Start time.Time
+	// Duration 耗时
+	Duration time.Duration
+	// RowsAffected 受影响行数，仅 EXEC 类操作成功时有效，否则为-1
+	RowsAffected int64
+	Err          error
+	// TxID 所在事务的编号，不在事务中时为0
+	TxID uint64
 }
 
-func (l Logger) ExecContext(ctx context.Context, conn driver.ExecerContext, query string, args []driver.NamedValue) (driver.Result, error) {
-	start := time.Now()
-	res, err := conn.ExecContext(ctx, query, args)
-	duration := time.Since(start)
+// Hook 日志驱动的钩子，可用于指标、链路追踪、审计等。
+// Before 返回的context会传给 After 和实际执行的驱动调用。
+// 每次语句执行只触发一次：带参数的语句在DSN未开启interpolateParams时以预处理语句(STMT-EXEC、STMT-QUERY)的形式出现；
+// 开启时为 EXEC、QUERY，此时驱动先执行，之后再依次调用 Before 和 After，Before 返回的context不会传给驱动
+type Hook interface {
+	Before(ctx context.Context, e *QueryEvent) context.Context
+	After(ctx context.Context, e *QueryEvent)
+}
 
-	sqlStr := InterpolateSQL(query, namedValueToInterface(args)...)
-	logSQL("[EXEC]", sqlStr, duration, err)
+// AfterFunc 只关心执行结果的钩子
+type AfterFunc func(ctx context.Context, e *QueryEvent)
 
-	return res, err
+func (f AfterFunc) Before(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
 }
 
-func (l Logger) Exec(conn driver.Execer, query string, args []driver.Value) (driver.Result, error) {
-	start := time.Now()
-	res, err := conn.Exec(query, args)
-	duration := time.Since(start)
+func (f AfterFunc) After(ctx context.Context, e *QueryEvent) {
+	f(ctx, e)
+}
 
-	sqlStr := InterpolateSQL(query, valuesToInterface(args)...)
-	logSQL("[EXEC]", sqlStr, duration, err)
+// DefaultSlowThreshold Db.Log 为nil或未设置 SlowThreshold 时的慢查询阈值
+const DefaultSlowThreshold = 300 * time.Millisecond
+
+// LogConfig Db级别的日志配置
+type LogConfig struct {
+	// SlowThreshold 慢查询阈值，为0时使用 DefaultSlowThreshold
+	SlowThreshold time.Duration
+	// Output 日志输出，为nil时经 xlog.DB 输出
+	Output io.Writer
+	// RedactArgs 为true时日志中的参数值替换为 <redacted>
+	RedactArgs bool
+	// SampleRate 普通语句的采样率，取值(0, 1]，为0时全部记录；慢查询和出错的语句总是记录
+	SampleRate float64
+	// Hooks 该Db额外的钩子
+	Hooks []Hook
+	// SlowQuery 不为nil时对慢SELECT执行EXPLAIN并输出慢查询记录
+	SlowQuery *SlowQueryConfig
+}
 
-	return res, err
+func (c *LogConfig) slowThreshold() time.Duration {
+	if c == nil || c.SlowThreshold <= 0 {
+		return DefaultSlowThreshold
+	}
+	return c.SlowThreshold
 }
 
-func (l Logger) Query(conn driver.Queryer, query string, args []driver.Value) (driver.Rows, error) {
-	start := time.Now()
-	rows, err := conn.Query(query, args)
-	duration := time.Since(start)
+func (c *LogConfig) hook(f *sqlfmt.Formatter) *LogHook {
+	h := &LogHook{
+		Formatter:     f,
+		SlowThreshold: c.slowThreshold(),
+		RedactArgs:    c.RedactArgs,
+		SampleRate:    c.SampleRate,
+	}
+	if c.Output != nil {
+		h.Logger = log.New(c.Output, "[MySQL] ", log.LstdFlags)
+	}
+	return h
+}
 
-	sqlStr := InterpolateSQL(query, valuesToInterface(args)...)
-	logSQL("[QUERY]", sqlStr, duration, err)
+// LogHook 内置的文本日志钩子，慢于阈值的语句会标记 [SLOW]
+type LogHook struct {
+	// SlowThreshold 慢查询阈值，为0时使用全局的 SlowThreshold
+	SlowThreshold time.Duration
+	// Logger 日志输出，为nil时经 xlog.DB 输出
+	Logger     *log.Logger
+	RedactArgs bool
+	SampleRate float64
+	// Formatter 嵌入参数使用的格式，为nil时使用 sqlfmt.Default(UTC)
+	Formatter *sqlfmt.Formatter
+}
 
-	return rows, err
+func (h *LogHook) Before(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
 }
 
-func (l Logger) QueryContext(ctx context.Context, conn driver.QueryerContext, query string, args []driver.NamedValue) (driver.Rows, error) {
+func (h *LogHook) After(ctx context.Context, e *QueryEvent) {
+	threshold := h.SlowThreshold
+	if threshold <= 0 {
+		threshold = SlowThreshold
+	}
+	slow := e.Duration > threshold
+	if !slow && e.Err == nil && h.SampleRate > 0 && h.SampleRate < 1 && rand.Float64() >= h.SampleRate {
+		return
+	}
+	var stmt string
+	if e.Query != "" {
+		args := e.Args
+		if h.RedactArgs {
+			args = make([]interface{}, len(e.Args))
+			for i := range args {
+				args[i] = "<redacted>"
+			}
+		}
+		f := h.Formatter
+		if f == nil {
+			f = sqlfmt.Default
+		}
+		stmt = f.Interpolate(e.Query, args...)
+	}
+	if h.Logger == nil {
+		isExec := (e.Op == OpExec || e.Op == OpStmtExec) && e.Err == nil
+		xlog.DBFormatted(isExec, e.Duration, e.RowsAffected, strings.TrimSpace(sqlTags("["+e.Op+"]", slow, e.Err)+" "+stmt))
+		return
+	}
+	if e.Query == "" {
+		h.Logger.Println("[" + e.Op + "]")
+		return
+	}
+	logSQL(h.Logger, "["+e.Op+"]", stmt, e.Duration, slow, e.Err)
+}
+
+var (
+	hooksMutex sync.RWMutex
+	hooks      []Hook
+	txSeq      uint64
+	// defaultLogHook 通过 RegisterWithLogging 注册的驱动使用的文本日志，输出到 SetLogOutput 设置的位置
+	defaultLogHook = &LogHook{Logger: logger}
+	registerOnce   sync.Once
+)
+
+// AddHook 注册全局钩子，对所有使用日志驱动的连接生效
+func AddHook(h Hook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	hooks = append(hooks, h)
+}
+
+// SetHooks 替换通过 AddHook 注册的全局钩子，不传参数时清空
+func SetHooks(h ...Hook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	hooks = append([]Hook(nil), h...)
+}
+
+func currentHooks() []Hook {
+	hooksMutex.RLock()
+	defer hooksMutex.RUnlock()
+	return hooks
+}
+
+func driverHooks() []Hook {
+	return append([]Hook{defaultLogHook}, currentHooks()...)
+}
+
+// observe 依次调用钩子并执行f，f返回受影响行数（未知时为-1）和错误
+func observe(ctx context.Context, hs []Hook, op, query string, args []interface{}, txID uint64, f func(ctx context.Context) (int64, error)) error {
+	e := &QueryEvent{
+		Op:           op,
+		Query:        query,
+		Args:         args,
+		Start:        time.Now(),
+		RowsAffected: -1,
+		TxID:         txID,
+	}
+	for _, h := range hs {
+		ctx = h.Before(ctx, e)
+	}
+	e.RowsAffected, e.Err = f(ctx)
+	e.Duration = time.Since(e.Start)
+	for _, h := range hs {
+		h.After(ctx, e)
+	}
+	return e.Err
+}
+
+// observeSkippable 同 observe，但先执行f：f返回 driver.ErrSkip 时 database/sql 会改用预处理语句重新执行，
+// 此时不触发钩子，避免同一条语句触发两次；否则按实际执行的时间依次调用 Before 和 After
+func observeSkippable(ctx context.Context, hs []Hook, op, query string, args []interface{}, txID uint64, f func(ctx context.Context) (int64, error)) error {
 	start := time.Now()
-	rows, err := conn.QueryContext(ctx, query, args)
+	n, err := f(ctx)
+	if err == driver.ErrSkip {
+		return err
+	}
+	e := &QueryEvent{
+		Op:           op,
+		Query:        query,
+		Args:         args,
+		Start:        start,
+		RowsAffected: -1,
+		TxID:         txID,
+	}
 	duration := time.Since(start)
+	for _, h := range hs {
+		ctx = h.Before(ctx, e)
+	}
+	e.RowsAffected, e.Err, e.Duration = n, err, duration
+	for _, h := range hs {
+		h.After(ctx, e)
+	}
+	return err
+}
 
-	sqlStr := InterpolateSQL(query, namedValueToInterface(args)...)
-	logSQL("[QUERY]", sqlStr, duration, err)
-
-	return rows, err
+func rowsAffected(res driver.Result, err error) (int64, error) {
+	if err != nil {
+		return -1, err
+	}
+	n, e := res.RowsAffected()
+	if e != nil {
+		return -1, nil
+	}
+	return n, nil
 }
 
 func namedValueToInterface(args []driver.NamedValue) []interface{} {
@@ -68,16 +255,21 @@ func namedValueToInterface(args []driver.NamedValue) []interface{} {
 	return values
 }
 
-func logSQL(tag, sql string, duration time.Duration, err error) {
+func logSQL(l *log.Logger, tag, sql string, duration time.Duration, isSlow bool, err error) {
+	l.Printf("%s %v %s\n", sqlTags(tag, isSlow, err), duration, sql)
+}
+
+// sqlTags 返回日志前的标记，如 [EXEC] [OK] [SLOW]
+func sqlTags(tag string, isSlow bool, err error) string {
 	slow := ""
-	if duration > SlowThreshold {
+	if isSlow {
 		slow = "[SLOW]"
 	}
 	status := "[OK]"
 	if err != nil {
 		status = "[ERR]"
 	}
-	log.Printf("%s %s %s %v %s\n", tag, status, slow, duration, sql)
+	return tag + " " + status + " " + slow
 }
 
 // wrappedDriver 包装 mysql.Driver，拦截 Open 以包装连接
@@ -90,7 +282,24 @@ func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
+	return wrapConn(conn, driverHooks), nil
+}
+
+// loggingConnector 包装 mysql 的 Connector，使每个Db可以使用自己的钩子而无需注册驱动
+type loggingConnector struct {
+	driver.Connector
+	hooks func() []Hook
+}
+
+func (c *loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return wrapConn(conn, c.hooks), nil
+}
 
+func wrapConn(conn driver.Conn, hooks func() []Hook) *wrappedConn {
 	// 包装连接，确保实现 ConnPrepareContext 和 ConnBeginTx
 	var cpCtx driver.ConnPrepareContext
 	if v, ok := conn.(driver.ConnPrepareContext); ok {
@@ -116,7 +325,8 @@ func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
 		ConnBeginTx:        cbTx,
 		ExecerContext:      execerCtx,
 		QueryerContext:     queryCtx,
-	}, nil
+		hooks:              hooks,
+	}
 }
 
 // wrappedConn 包装连接，确保实现 PrepareContext 和 BeginTx 拦截
@@ -126,6 +336,10 @@ type wrappedConn struct {
 	driver.ConnBeginTx
 	driver.ExecerContext
 	driver.QueryerContext
+
+	// txID 当前连接上进行中的事务编号，database/sql 保证同一连接不会并发使用
+	txID  uint64
+	hooks func() []Hook
 }
 
 func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
@@ -137,86 +351,103 @@ func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.
 		return &loggedStmt{
 			Stmt:  stmt,
 			query: query,
+			conn:  c,
 		}, nil
 	}
 	return nil, driver.ErrSkip
 }
 
 func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
-	if c.ConnBeginTx != nil {
-		tx, err := c.ConnBeginTx.BeginTx(ctx, opts)
-		if err != nil {
-			return nil, err
+	id := atomic.AddUint64(&txSeq, 1)
+	var tx driver.Tx
+	err := observe(ctx, c.hooks(), OpBegin, "", nil, id, func(ctx context.Context) (int64, error) {
+		var err error
+		if c.ConnBeginTx != nil {
+			tx, err = c.ConnBeginTx.BeginTx(ctx, opts)
+		} else {
+			// fallback
+			tx, err = c.Conn.Begin()
 		}
-		return &wrappedTx{Tx: tx}, nil
+		return -1, err
+	})
+	if err != nil {
+		return nil, err
 	}
-	// fallback
-	return c.Conn.Begin()
+	c.txID = id
+	return &wrappedTx{Tx: tx, conn: c, id: id}, nil
 }
 
 // wrappedTx 包装事务，拦截 Commit 和 Rollback
 type wrappedTx struct {
 	driver.Tx
+	conn *wrappedConn
+	id   uint64
 }
 
 func (t *wrappedTx) Commit() error {
-	log.Println("[TX COMMIT]")
-	return t.Tx.Commit()
+	defer t.end()
+	return observe(context.Background(), t.conn.hooks(), OpCommit, "", nil, t.id, func(ctx context.Context) (int64, error) {
+		return -1, t.Tx.Commit()
+	})
 }
 
 func (t *wrappedTx) Rollback() error {
-	log.Println("[TX ROLLBACK]")
-	return t.Tx.Rollback()
+	defer t.end()
+	return observe(context.Background(), t.conn.hooks(), OpRollback, "", nil, t.id, func(ctx context.Context) (int64, error) {
+		return -1, t.Tx.Rollback()
+	})
+}
+
+func (t *wrappedTx) end() {
+	if t.conn.txID == t.id {
+		t.conn.txID = 0
+	}
 }
 
 // loggedStmt 包装语句，拦截 Exec 和 Query
 type loggedStmt struct {
 	driver.Stmt
 	query string
+	conn  *wrappedConn
 }
 
 func (s *loggedStmt) Exec(args []driver.Value) (driver.Result, error) {
-	start := time.Now()
-	res, err := s.Stmt.Exec(args)
-	duration := time.Since(start)
-
-	sqlStr := InterpolateSQL(s.query, valuesToInterface(args)...)
-	logSQL("[STMT-EXEC]", sqlStr, duration, err)
-
+	var res driver.Result
+	err := observe(context.Background(), s.conn.hooks(), OpStmtExec, s.query, valuesToInterface(args), s.conn.txID, func(ctx context.Context) (int64, error) {
+		var err error
+		res, err = s.Stmt.Exec(args)
+		return rowsAffected(res, err)
+	})
 	return res, err
 }
 
 func (s *loggedStmt) Query(args []driver.Value) (driver.Rows, error) {
-	start := time.Now()
-	rows, err := s.Stmt.Query(args)
-	duration := time.Since(start)
-
-	sqlStr := InterpolateSQL(s.query, valuesToInterface(args)...)
-	logSQL("[STMT-QUERY]", sqlStr, duration, err)
-
+	var rows driver.Rows
+	err := observe(context.Background(), s.conn.hooks(), OpStmtQuery, s.query, valuesToInterface(args), s.conn.txID, func(ctx context.Context) (int64, error) {
+		var err error
+		rows, err = s.Stmt.Query(args)
+		return -1, err
+	})
 	return rows, err
 }
 
 func (s *loggedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	start := time.Now()
-	execCtx := s.Stmt.(driver.StmtExecContext)
-	res, err := execCtx.ExecContext(ctx, args)
-	duration := time.Since(start)
-	sqlStr := InterpolateSQL(s.query, namedValueToInterface(args)...)
-	logSQL("[STMT-EXEC-CONTEXT]", sqlStr, duration, err)
-
+	var res driver.Result
+	err := observe(ctx, s.conn.hooks(), OpStmtExec, s.query, namedValueToInterface(args), s.conn.txID, func(ctx context.Context) (int64, error) {
+		var err error
+		res, err = s.Stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+		return rowsAffected(res, err)
+	})
 	return res, err
 }
 
 func (s *loggedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	start := time.Now()
-	queryCtx := s.Stmt.(driver.StmtQueryContext)
-	rows, err := queryCtx.QueryContext(ctx, args)
-	duration := time.Since(start)
-
-	sqlStr := InterpolateSQL(s.query, namedValueToInterface(args)...)
-	logSQL("[STMT-QUERY-CONTEXT]", sqlStr, duration, err)
-
+	var rows driver.Rows
+	err := observe(ctx, s.conn.hooks(), OpStmtQuery, s.query, namedValueToInterface(args), s.conn.txID, func(ctx context.Context) (int64, error) {
+		var err error
+		rows, err = s.Stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+		return -1, err
+	})
 	return rows, err
 }
 
@@ -228,35 +459,90 @@ func valuesToInterface(vals []driver.Value) []interface{} {
 	return values
 }
 
+// ExecContext 带参数时mysql驱动在未开启interpolateParams或无法内插参数时返回 driver.ErrSkip，
+// 由 database/sql 改用预处理语句执行，钩子只在实际执行的那一次触发
 func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	start := time.Now()
+	run := observe
+	if len(args) > 0 {
+		run = observeSkippable
+	}
+	var res driver.Result
+	err := run(ctx, c.hooks(), OpExec, query, namedValueToInterface(args), c.txID, func(ctx context.Context) (int64, error) {
+		var err error
+		res, err = c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+		return rowsAffected(res, err)
+	})
+	return res, err
+}
 
-	execerCtx := c.Conn.(driver.ExecerContext)
-	res, err := execerCtx.ExecContext(ctx, query, args)
+// QueryContext 同 ExecContext
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	run := observe
+	if len(args) > 0 {
+		run = observeSkippable
+	}
+	var rows driver.Rows
+	err := run(ctx, c.hooks(), OpQuery, query, namedValueToInterface(args), c.txID, func(ctx context.Context) (int64, error) {
+		var err error
+		rows, err = c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+		return -1, err
+	})
+	return rows, err
+}
 
-	duration := time.Since(start)
-	sqlStr := InterpolateSQL(query, namedValueToInterface(args)...)
-	logSQL("[CONN-EXEC-CONTEXT]", sqlStr, duration, err)
+// Logger 实现 sqlmw.Interceptor 接口，拦截 Exec、Query 并交给 RegisterWithLogging 使用的钩子(内置文本日志和 AddHook 注册的钩子)。
+//
+// Deprecated: 使用 Hook，通过 AddHook 或 LogConfig.Hooks 注册
+type Logger struct {
+	sqlmw.NullInterceptor
+}
 
+func (l Logger) ExecContext(ctx context.Context, conn driver.ExecerContext, query string, args []driver.NamedValue) (driver.Result, error) {
+	var res driver.Result
+	err := observeSkippable(ctx, driverHooks(), OpExec, query, namedValueToInterface(args), 0, func(ctx context.Context) (int64, error) {
+		var err error
+		res, err = conn.ExecContext(ctx, query, args)
+		return rowsAffected(res, err)
+	})
 	return res, err
 }
 
-func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	start := time.Now()
-
-	queryerCtx := c.Conn.(driver.QueryerContext)
-	rows, err := queryerCtx.QueryContext(ctx, query, args)
+func (l Logger) Exec(conn driver.Execer, query string, args []driver.Value) (driver.Result, error) {
+	var res driver.Result
+	err := observeSkippable(context.Background(), driverHooks(), OpExec, query, valuesToInterface(args), 0, func(ctx context.Context) (int64, error) {
+		var err error
+		res, err = conn.Exec(query, args)
+		return rowsAffected(res, err)
+	})
+	return res, err
+}
 
-	duration := time.Since(start)
-	sqlStr := InterpolateSQL(query, namedValueToInterface(args)...)
-	logSQL("[CONN-QUERY-CONTEXT]", sqlStr, duration, err)
+func (l Logger) Query(conn driver.Queryer, query string, args []driver.Value) (driver.Rows, error) {
+	var rows driver.Rows
+	err := observeSkippable(context.Background(), driverHooks(), OpQuery, query, valuesToInterface(args), 0, func(ctx context.Context) (int64, error) {
+		var err error
+		rows, err = conn.Query(query, args)
+		return -1, err
+	})
+	return rows, err
+}
 
+func (l Logger) QueryContext(ctx context.Context, conn driver.QueryerContext, query string, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := observeSkippable(ctx, driverHooks(), OpQuery, query, namedValueToInterface(args), 0, func(ctx context.Context) (int64, error) {
+		var err error
+		rows, err = conn.QueryContext(ctx, query, args)
+		return -1, err
+	})
 	return rows, err
 }
 
-// RegisterWithLogging 注册带日志拦截的自定义驱动
+// RegisterWithLogging 注册带日志拦截的自定义驱动 mysql-with-logger，可重复调用。
+// Db 的 Print 不依赖该驱动，日志按 Db.Log 单独配置
 func RegisterWithLogging() {
-	sql.Register("mysql-with-logger", &wrappedDriver{
-		Driver: &mysql.MySQLDriver{},
+	registerOnce.Do(func() {
+		sql.Register("mysql-with-logger", &wrappedDriver{
+			Driver: &mysql.MySQLDriver{},
+		})
 	})
 }
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+// skipConn 模拟mysql驱动：带参数时返回 driver.ErrSkip(未开启interpolateParams)或执行成功
+type skipConn struct {
+	driver.Conn
+	interpolate bool
+	calls       int
+}
+
+func (c *skipConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.calls++
+	if len(args) > 0 && !c.interpolate {
+		return nil, driver.ErrSkip
+	}
+	return driver.RowsAffected(2), nil
+}
+
+type countingHook struct {
+	before, after int
+	last          QueryEvent
+}
+
+func (h *countingHook) Before(ctx context.Context, e *QueryEvent) context.Context {
+	h.before++
+	return ctx
+}
+
+func (h *countingHook) After(ctx context.Context, e *QueryEvent) {
+	h.after++
+	h.last = *e
+}
+
+func TestWrappedConnExecSkip(t *testing.T) {
+	tests := []struct {
+		name        string
+		interpolate bool
+		args        []driver.NamedValue
+		wantErr     error
+		wantHooks   int
+	}{
+		{name: "no args", wantHooks: 1},
+		{name: "args without interpolateParams", args: []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}, wantErr: driver.ErrSkip},
+		{name: "args with interpolateParams", interpolate: true, args: []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}, wantHooks: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &skipConn{interpolate: tt.interpolate}
+			hook := &countingHook{}
+			c := wrapConn(conn, func() []Hook { return []Hook{hook} })
+			_, err := c.ExecContext(context.Background(), "UPDATE t SET a = ?", tt.args)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if conn.calls != 1 {
+				t.Errorf("driver called %d times, want 1", conn.calls)
+			}
+			if hook.before != tt.wantHooks || hook.after != tt.wantHooks {
+				t.Errorf("hooks called before=%d after=%d, want %d", hook.before, hook.after, tt.wantHooks)
+			}
+			if tt.wantHooks > 0 && (hook.last.Op != OpExec || hook.last.RowsAffected != 2) {
+				t.Errorf("event = %+v", hook.last)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	// fieldPlans 缓存每个结构体类型的列名到字段下标的映射
+	fieldPlans sync.Map
+)
+
+type queryer interface {
+	QueryContext(ctx context.Context, sql string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Select 查询多行并写入dest，dest须为切片指针，元素可为结构体、结构体指针或单列的基础类型。
+// 结构体字段按 db:"col" 标签匹配列名，无标签时使用字段名的下划线形式，db:"-" 表示忽略
+func (s *Db) Select(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	return selectContext(ctx, s, dest, sql, args...)
+}
+
+// Get 查询单行并写入dest，没有结果时返回 sql.ErrNoRows
+func (s *Db) Get(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	return getContext(ctx, s, dest, sql, args...)
+}
+
+// Select 同 Db.Select
+func (s *Tx) Select(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	return selectContext(ctx, s, dest, sql, args...)
+}
+
+// Get 同 Db.Get
+func (s *Tx) Get(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	return getContext(ctx, s, dest, sql, args...)
+}
+
+func selectContext(ctx context.Context, q queryer, dest interface{}, query string, args ...interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("db: Select(non-pointer %T)", dest)
+	}
+	slice := v.Elem()
+	if slice.Kind() != reflect.Slice {
+		return fmt.Errorf("db: Select(non-slice %T)", dest)
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return scanAll(rows, slice)
+}
+
+func getContext(ctx context.Context, q queryer, dest interface{}, query string, args ...interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("db: Get(non-pointer %T)", dest)
+	}
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	targets, err := scanTargets(columns, v.Elem())
+	if err != nil {
+		return err
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// ScanRows 将rows中剩余的所有行写入dest，规则同 Select，调用方负责关闭rows
+func ScanRows(rows *sql.Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("db: ScanRows(non-slice pointer %T)", dest)
+	}
+	return scanAll(rows, v.Elem())
+}
+
+func scanAll(rows *sql.Rows, slice reflect.Value) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	for rows.Next() {
+		elem := reflect.New(elemType)
+		targets, err := scanTargets(columns, elem.Elem())
+		if err != nil {
+			return err
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return err
+		}
+		if isPtr {
+			slice.Set(reflect.Append(slice, elem))
+		} else {
+			slice.Set(reflect.Append(slice, elem.Elem()))
+		}
+	}
+	return rows.Err()
+}
+
+// scanTargets 返回v中与columns对应的Scan参数
+func scanTargets(columns []string, v reflect.Value) ([]interface{}, error) {
+	if !isStructDest(v.Type()) {
+		if len(columns) != 1 {
+			return nil, fmt.Errorf("db: scannable dest type %s with %d columns", v.Type(), len(columns))
+		}
+		return []interface{}{v.Addr().Interface()}, nil
+	}
+	plan := fieldPlan(v.Type())
+	targets := make([]interface{}, len(columns))
+	for i, column := range columns {
+		index, ok := plan[column]
+		if !ok {
+			return nil, fmt.Errorf("db: missing destination for column %q in %s", column, v.Type())
+		}
+		targets[i] = fieldByIndexAlloc(v, index).Addr().Interface()
+	}
+	return targets, nil
+}
+
+func isStructDest(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	// time.Time、sql.NullString 等实现了Scanner的结构体按单列处理
+	if reflect.PtrTo(t).Implements(scannerType) {
+		return false
+	}
+	return t.PkgPath() != "time" || t.Name() != "Time"
+}
+
+// fieldPlan 返回t的列名到字段下标的映射，结果按类型缓存
+func fieldPlan(t reflect.Type) map[string][]int {
+	if plan, ok := fieldPlans.Load(t); ok {
+		return plan.(map[string][]int)
+	}
+	plan := map[string][]int{}
+	buildFieldPlan(t, nil, plan)
+	actual, _ := fieldPlans.LoadOrStore(t, plan)
+	return actual.(map[string][]int)
+}
+
+func buildFieldPlan(t reflect.Type, parent []int, plan map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		index := make([]int, len(parent)+1)
+		copy(index, parent)
+		index[len(parent)] = i
+
+		if field.Anonymous && tag == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if isStructDest(ft) {
+				buildFieldPlan(ft, index, plan)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = toSnakeCase(field.Name)
+		}
+		// 外层字段优先于嵌入结构体中的同名字段
+		if old, ok := plan[name]; ok && len(old) <= len(index) {
+			continue
+		}
+		plan[name] = index
+	}
+}
+
+// fieldByIndexAlloc 同 FieldByIndex，遇到nil的嵌入指针时自动分配
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func toSnakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// IsNoRows 是否为未查询到数据
+func IsNoRows(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
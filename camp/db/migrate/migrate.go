@@ -0,0 +1,311 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mufe/golang-base/camp/db"
+	"github.com/mufe/golang-base/camp/sqlfmt"
+	"github.com/mufe/golang-base/camp/xlog"
+)
+
+const (
+	DefaultTable       = "schema_migrations"
+	DefaultLockName    = "schema_migrations"
+	DefaultLockTimeout = 30 * time.Second
+
+	errNoSuchTable uint16 = 1146
+)
+
+// 文件名格式：<版本号>_<名称>.up.sql / <版本号>_<名称>.down.sql
+var fileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration 单个版本的变更
+type Migration struct {
+	Version int64
+	Name    string
+	// Up、Down 按 sqlfmt.Split 拆分后逐条执行，存储过程和触发器需用 DELIMITER 切换分隔符
+	Up   string
+	Down string
+}
+
+// Status 版本状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator 按版本顺序执行数据库变更，已执行的版本记录在 Table 中
+type Migrator struct {
+	db         *db.Db
+	migrations []Migration
+
+	Table       string
+	LockName    string
+	LockTimeout time.Duration
+	// DryRun 为true时只输出将要执行的语句，不修改数据库
+	DryRun bool
+	// Out DryRun的输出，默认为标准输出
+	Out io.Writer
+}
+
+// New 从fsys的dir目录读取变更文件，可传入 embed.FS
+func New(d *db.Db, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:          d,
+		migrations:  migrations,
+		Table:       DefaultTable,
+		LockName:    DefaultLockName,
+		LockTimeout: DefaultLockTimeout,
+		Out:         os.Stdout,
+	}, nil
+}
+
+// NewFromDir 从本地目录读取变更文件
+func NewFromDir(d *db.Db, dir string) (*Migrator, error) {
+	return New(d, os.DirFS(dir), ".")
+}
+
+// Load 读取并按版本号排序变更文件
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileRegexp.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: invalid version in %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d has different names %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d has no up file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrations 返回已加载的变更
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up 执行所有未执行的版本
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.run(ctx, func(ctx context.Context, conn *sql.Conn, applied map[int64]time.Time) error {
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mg, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down 按版本倒序回滚最近执行的n个版本
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.run(ctx, func(ctx context.Context, conn *sql.Conn, applied map[int64]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(mg.Down) == "" {
+				return fmt.Errorf("migrate: version %d has no down file", mg.Version)
+			}
+			if err := m.apply(ctx, conn, mg, false); err != nil {
+				return err
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+// Status 返回每个版本的执行状态
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.GetDb().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// 新库还没有版本表时所有版本均未执行
+	applied, err := m.applied(ctx, conn, true)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		at, ok := applied[mg.Version]
+		result = append(result, Status{
+			Version:   mg.Version,
+			Name:      mg.Name,
+			Applied:   ok,
+			AppliedAt: at,
+		})
+	}
+	return result, nil
+}
+
+func (m *Migrator) run(ctx context.Context, f func(ctx context.Context, conn *sql.Conn, applied map[int64]time.Time) error) error {
+	conn, err := m.db.GetDb().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !m.DryRun {
+		if err := m.lock(ctx, conn); err != nil {
+			return err
+		}
+		defer m.unlock(conn)
+	}
+	if err := m.exec(ctx, conn, m.createTableSQL()); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, conn, m.DryRun)
+	if err != nil {
+		return err
+	}
+	return f(ctx, conn, applied)
+}
+
+// lock 使用 GET_LOCK 避免多个实例同时执行变更，锁与连接绑定
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) error {
+	var got sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", m.LockName, int(m.LockTimeout/time.Second)).Scan(&got)
+	if err != nil {
+		return err
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return fmt.Errorf("migrate: acquire lock %q timeout", m.LockName)
+	}
+	return nil
+}
+
+func (m *Migrator) unlock(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", m.LockName); err != nil {
+		xlog.ErrorP(err)
+	}
+}
+
+func (m *Migrator) createTableSQL() string {
+	return "CREATE TABLE IF NOT EXISTS `" + m.Table + "` (" +
+		"`version` BIGINT NOT NULL PRIMARY KEY, " +
+		"`name` VARCHAR(255) NOT NULL, " +
+		"`applied_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+}
+
+// applied 返回已执行的版本，missingOK为true时版本表不存在视为没有执行过任何版本
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn, missingOK bool) (map[int64]time.Time, error) {
+	applied := map[int64]time.Time{}
+	rows, err := conn.QueryContext(ctx, "SELECT `version`, CAST(`applied_at` AS CHAR) FROM `"+m.Table+"`")
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if missingOK && errors.As(err, &mysqlErr) && mysqlErr.Number == errNoSuchTable {
+			return applied, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	// applied_at 按连接的 Config.Loc 解析，与 parseTime=true 时驱动的处理一致
+	loc := m.db.Formatter().Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	for rows.Next() {
+		var (
+			version int64
+			at      string
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", at, loc)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: version %d: invalid applied_at %q: %w", version, at, err)
+		}
+		applied[version] = t
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mg Migration, up bool) error {
+	script, direction := mg.Up, "up"
+	if !up {
+		script, direction = mg.Down, "down"
+	}
+	if !m.DryRun {
+		xlog.Infof("migrate %s %d_%s", direction, mg.Version, mg.Name)
+	}
+	for _, statement := range sqlfmt.Split(script) {
+		if err := m.exec(ctx, conn, statement); err != nil {
+			return fmt.Errorf("migrate: %s %d_%s: %w", direction, mg.Version, mg.Name, err)
+		}
+	}
+	if up {
+		return m.exec(ctx, conn, "INSERT INTO `"+m.Table+"` (`version`, `name`) VALUES (?, ?)", mg.Version, mg.Name)
+	}
+	return m.exec(ctx, conn, "DELETE FROM `"+m.Table+"` WHERE `version` = ?", mg.Version)
+}
+
+func (m *Migrator) exec(ctx context.Context, conn *sql.Conn, query string, args ...interface{}) error {
+	if m.DryRun {
+		_, err := fmt.Fprintln(m.Out, m.db.Formatter().Interpolate(query, args...)+";")
+		return err
+	}
+	_, err := conn.ExecContext(ctx, query, args...)
+	return err
+}
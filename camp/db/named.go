@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/mufe/golang-base/camp/sqlfmt"
+)
+
+type namedQuery struct {
+	query string
+	names []string
+}
+
+// namedQueries 缓存命名参数语句的编译结果
+var namedQueries sync.Map
+
+// Named 将 :name 形式的命名参数编译为mysql的 ? 占位符，参数从arg中按名称读取。
+// arg 可以是结构体（字段名规则同 Select）或 map[string]interface{}；
+// 绑定的值为切片时按 In 的规则展开
+func Named(query string, arg interface{}) (string, []interface{}, error) {
+	nq := compileNamed(query)
+	args, err := bindNamed(nq.names, arg)
+	if err != nil {
+		return "", nil, err
+	}
+	return In(nq.query, args...)
+}
+
+func compileNamed(query string) namedQuery {
+	if v, ok := namedQueries.Load(query); ok {
+		return v.(namedQuery)
+	}
+	var (
+		buf   strings.Builder
+		names []string
+	)
+	for _, t := range sqlfmt.Tokenize(query) {
+		if t.Kind != sqlfmt.Text {
+			buf.WriteString(t.Text)
+			continue
+		}
+		text := t.Text
+		for i := 0; i < len(text); i++ {
+			c := text[i]
+			switch {
+			case c == ':' && i+1 < len(text) && text[i+1] == ':':
+				// :: 原样保留为单个冒号
+				buf.WriteByte(':')
+				i++
+			case c == ':' && i+1 < len(text) && isNameStart(text[i+1]):
+				end := i + 1
+				for end < len(text) && isNameChar(text[end]) {
+					end++
+				}
+				names = append(names, text[i+1:end])
+				buf.WriteByte('?')
+				i = end - 1
+			default:
+				buf.WriteByte(c)
+			}
+		}
+	}
+	nq := namedQuery{query: buf.String(), names: names}
+	namedQueries.Store(query, nq)
+	return nq
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9') || c == '.'
+}
+
+func bindNamed(names []string, arg interface{}) ([]interface{}, error) {
+	args := make([]interface{}, len(names))
+	if len(names) == 0 {
+		return args, nil
+	}
+	switch m := arg.(type) {
+	case map[string]interface{}:
+		for i, name := range names {
+			v, ok := m[name]
+			if !ok {
+				return nil, fmt.Errorf("db: missing named parameter :%s", name)
+			}
+			args[i] = v
+		}
+		return args, nil
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(arg))
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("db: unsupported named argument type %T", arg)
+	}
+	plan := fieldPlan(v.Type())
+	for i, name := range names {
+		index, ok := plan[name]
+		if !ok {
+			return nil, fmt.Errorf("db: missing named parameter :%s in %s", name, v.Type())
+		}
+		field, err := v.FieldByIndexErr(index)
+		if err != nil {
+			// 嵌入的结构体指针为nil
+			args[i] = nil
+			continue
+		}
+		args[i] = field.Interface()
+	}
+	return args, nil
+}
+
+// NamedExec 执行命名参数语句
+func (s *Db) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return s.NamedExecContext(context.Background(), query, arg)
+}
+
+// NamedExecContext 带context执行命名参数语句
+func (s *Db) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	q, args, err := Named(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return s.ExecContext(ctx, q, args...)
+}
+
+// NamedQuery 命名参数查询
+func (s *Db) NamedQuery(query string, arg interface{}) (*sql.Rows, error) {
+	return s.NamedQueryContext(context.Background(), query, arg)
+}
+
+// NamedQueryContext 带context的命名参数查询
+func (s *Db) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sql.Rows, error) {
+	q, args, err := Named(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return s.QueryContext(ctx, q, args...)
+}
+
+// NamedExec 在事务中执行命名参数语句
+func (s *Tx) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return s.NamedExecContext(s.Context(), query, arg)
+}
+
+// NamedExecContext 在事务中带context执行命名参数语句
+func (s *Tx) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	q, args, err := Named(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return s.ExecContext(ctx, q, args...)
+}
+
+// NamedQuery 在事务中命名参数查询
+func (s *Tx) NamedQuery(query string, arg interface{}) (*sql.Rows, error) {
+	return s.NamedQueryContext(s.Context(), query, arg)
+}
+
+// NamedQueryContext 在事务中带context的命名参数查询
+func (s *Tx) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sql.Rows, error) {
+	q, args, err := Named(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return s.QueryContext(ctx, q, args...)
+}
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileNamed(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantQuery string
+		wantNames []string
+	}{
+		{
+			name:      "names",
+			query:     "UPDATE user SET name = :name WHERE id = :id",
+			wantQuery: "UPDATE user SET name = ? WHERE id = ?",
+			wantNames: []string{"name", "id"},
+		},
+		{
+			name:      "repeated name and dotted name",
+			query:     "a = :user.id OR b = :user.id AND c=:x_1",
+			wantQuery: "a = ? OR b = ? AND c=?",
+			wantNames: []string{"user.id", "user.id", "x_1"},
+		},
+		{
+			name:      "double colon",
+			query:     "SELECT a::b, :v",
+			wantQuery: "SELECT a:b, ?",
+			wantNames: []string{"v"},
+		},
+		{
+			name:      "inside literals",
+			query:     "SELECT ':a', \":b\", `:c` FROM t WHERE d = :d",
+			wantQuery: "SELECT ':a', \":b\", `:c` FROM t WHERE d = ?",
+			wantNames: []string{"d"},
+		},
+		{
+			name:      "inside comments",
+			query:     "SELECT 1 /* :a */ FROM t -- :b\nWHERE c = :c # :d",
+			wantQuery: "SELECT 1 /* :a */ FROM t -- :b\nWHERE c = ? # :d",
+			wantNames: []string{"c"},
+		},
+		{
+			name:      "colon without name",
+			query:     "SELECT '10:00', : , :1",
+			wantQuery: "SELECT '10:00', : , :1",
+		},
+		{
+			name:      "colon at end",
+			query:     "a = :",
+			wantQuery: "a = :",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nq := compileNamed(tt.query)
+			if nq.query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", nq.query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(nq.names, tt.wantNames) {
+				t.Errorf("names = %q, want %q", nq.names, tt.wantNames)
+			}
+		})
+	}
+}
+
+type namedBase struct {
+	ID int64
+}
+
+type namedUser struct {
+	namedBase
+	Name   string
+	Status int `db:"state"`
+}
+
+func TestNamed(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		arg       interface{}
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   string
+	}{
+		{
+			name:      "struct",
+			query:     "UPDATE user SET name = :name, state = :state WHERE id = :id",
+			arg:       namedUser{namedBase: namedBase{ID: 3}, Name: "bob", Status: 1},
+			wantQuery: "UPDATE user SET name = ?, state = ? WHERE id = ?",
+			wantArgs:  []interface{}{"bob", 1, int64(3)},
+		},
+		{
+			name:      "struct pointer",
+			query:     "SELECT :id",
+			arg:       &namedUser{namedBase: namedBase{ID: 3}},
+			wantQuery: "SELECT ?",
+			wantArgs:  []interface{}{int64(3)},
+		},
+		{
+			name:      "map with slice",
+			query:     "SELECT * FROM user WHERE id IN (:ids) AND name = :name",
+			arg:       map[string]interface{}{"ids": []int{1, 2}, "name": "bob"},
+			wantQuery: "SELECT * FROM user WHERE id IN (?, ?) AND name = ?",
+			wantArgs:  []interface{}{1, 2, "bob"},
+		},
+		{
+			name:      "bytes",
+			query:     "UPDATE t SET data = :data",
+			arg:       map[string]interface{}{"data": []byte("raw")},
+			wantQuery: "UPDATE t SET data = ?",
+			wantArgs:  []interface{}{[]byte("raw")},
+		},
+		{
+			name:      "no names",
+			query:     "SELECT 1",
+			arg:       nil,
+			wantQuery: "SELECT 1",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name:    "missing map key",
+			query:   "SELECT :a",
+			arg:     map[string]interface{}{},
+			wantErr: "missing named parameter :a",
+		},
+		{
+			name:    "missing field",
+			query:   "SELECT :age",
+			arg:     namedUser{},
+			wantErr: "missing named parameter :age",
+		},
+		{
+			name:    "empty slice",
+			query:   "SELECT * FROM t WHERE id IN (:ids)",
+			arg:     map[string]interface{}{"ids": []int{}},
+			wantErr: ErrEmptyIn.Error(),
+		},
+		{
+			name:    "unsupported type",
+			query:   "SELECT :a",
+			arg:     1,
+			wantErr: "unsupported named argument type int",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := Named(tt.query, tt.arg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
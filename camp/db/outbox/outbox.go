@@ -0,0 +1,116 @@
+// Package outbox 实现事务性发件箱：在业务事务中写入事件，由 Relay 在事务提交后异步投递，
+// 保证"修改数据"和"发布事件"同时成功或同时失败：
+//
+//	o := outbox.New(d)
+//	err := d.WithTransaction(func(tx *db.Tx) error {
+//		if _, err := tx.Exec("UPDATE orders SET status = ? WHERE id = ?", paid, id); err != nil {
+//			return err
+//		}
+//		return o.Add(tx, "order.paid", strconv.FormatInt(id, 10), OrderPaid{ID: id})
+//	})
+//
+//	relay := outbox.NewRelay(o)
+//	relay.Handle("order.paid", &outbox.HTTPPublisher{URL: "https://example.com/hooks/order"})
+//	relay.Start()
+//	defer relay.Stop()
+//
+// 投递语义为至少一次，消费方应使用 Event.ID 去重
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mufe/golang-base/camp/db"
+)
+
+const DefaultTable = "outbox"
+
+// 事件状态
+const (
+	StatusPending   = 0
+	StatusDelivered = 1
+	// StatusFailed 超过最大重试次数或遇到 Permanent 错误，不再投递
+	StatusFailed = 2
+)
+
+// Event 发件箱中的事件
+type Event struct {
+	ID    int64
+	Topic string
+	// Key 业务主键，可用于消费方的分区或去重
+	Key       string
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// Outbox 发件箱表
+type Outbox struct {
+	db    *db.Db
+	Table string
+}
+
+// New 使用默认表名创建发件箱
+func New(d *db.Db) *Outbox {
+	return &Outbox{db: d, Table: DefaultTable}
+}
+
+// CreateTable 建表，已存在时不做修改
+func (o *Outbox) CreateTable(ctx context.Context) error {
+	_, err := o.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id BIGINT NOT NULL AUTO_INCREMENT,
+	topic VARCHAR(191) NOT NULL,
+	event_key VARCHAR(191) NOT NULL DEFAULT '',
+	payload MEDIUMBLOB NOT NULL,
+	status TINYINT NOT NULL DEFAULT 0,
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+	lease_token VARCHAR(64) NOT NULL DEFAULT '',
+	last_error TEXT NULL,
+	created_at DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+	delivered_at DATETIME(3) NULL,
+	PRIMARY KEY (id),
+	KEY idx_status_next (status, next_attempt_at)
+)`, o.table()))
+	return err
+}
+
+// Add 在事务tx中写入事件，payload为[]byte、string时原样保存，其他类型保存为JSON
+func (o *Outbox) Add(tx *db.Tx, topic, key string, payload interface{}) error {
+	return o.AddContext(tx.Context(), tx, topic, key, payload)
+}
+
+// AddContext 带context写入事件
+func (o *Outbox) AddContext(ctx context.Context, tx *db.Tx, topic, key string, payload interface{}) error {
+	var data []byte
+	switch p := payload.(type) {
+	case []byte:
+		data = p
+	case string:
+		data = []byte(p)
+	default:
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO "+o.table()+" (topic, event_key, payload) VALUES (?, ?, ?)", topic, key, data)
+	return err
+}
+
+// Purge 删除投递成功超过olderThan的事件，返回删除的行数
+func (o *Outbox) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	result, err := o.db.ExecContext(ctx, "DELETE FROM "+o.table()+" WHERE status = ? AND delivered_at < NOW(3) - INTERVAL ? MICROSECOND",
+		StatusDelivered, olderThan.Microseconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (o *Outbox) table() string {
+	return db.QuoteIdentifier(o.Table)
+}
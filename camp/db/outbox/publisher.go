@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// Publisher 投递事件，返回nil表示投递成功。返回 Permanent 包装的错误时不再重试
+type Publisher interface {
+	Publish(ctx context.Context, e *Event) error
+}
+
+// PublisherFunc 进程内的处理函数
+type PublisherFunc func(ctx context.Context, e *Event) error
+
+func (f PublisherFunc) Publish(ctx context.Context, e *Event) error {
+	return f(ctx, e)
+}
+
+// HTTPPublisher 以POST请求将Payload发送到URL，2xx为成功，4xx(408、429除外)不再重试。
+// 请求头中带有 X-Outbox-Id、X-Outbox-Topic、X-Outbox-Key，
+// 设置Secret时带有 X-Outbox-Signature: sha256=<hex(hmac_sha256(Secret, body))>
+type HTTPPublisher struct {
+	URL    string
+	Client *http.Client
+	// ContentType 默认为 application/json
+	ContentType string
+	Header      http.Header
+	Secret      string
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, e *Event) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(e.Payload))
+	if err != nil {
+		return Permanent(err)
+	}
+	for k, v := range p.Header {
+		req.Header[k] = v
+	}
+	contentType := p.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Outbox-Id", strconv.FormatInt(e.ID, 10))
+	req.Header.Set("X-Outbox-Topic", e.Topic)
+	req.Header.Set("X-Outbox-Key", e.Key)
+	if p.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(p.Secret))
+		mac.Write(e.Payload)
+		req.Header.Set("X-Outbox-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("outbox: %s returned %d: %s", p.URL, resp.StatusCode, bytes.TrimSpace(body))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
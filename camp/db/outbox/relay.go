@@ -0,0 +1,313 @@
+package outbox
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mufe/golang-base/camp/db"
+	"github.com/mufe/golang-base/camp/xlog"
+)
+
+const (
+	DefaultInterval       = time.Second
+	DefaultBatchSize      = 100
+	DefaultLeaseTimeout   = time.Minute
+	DefaultPublishTimeout = 10 * time.Second
+
+	// maxErrorLen last_error保存的最大长度
+	maxErrorLen = 1024
+)
+
+// Backoff 投递失败后的重试策略
+type Backoff struct {
+	// MaxAttempts 最多投递次数（含第一次），<=0 时不限
+	MaxAttempts int
+	// BaseDelay 第一次重试前的等待上限，之后每次翻倍，为0时不等待
+	BaseDelay time.Duration
+	// MaxDelay 等待上限
+	MaxDelay time.Duration
+}
+
+// DefaultBackoff 默认策略：最多投递10次，第n次重试前随机等待0到1s×2^(n-1)，上限10min
+func DefaultBackoff() Backoff {
+	return Backoff{
+		MaxAttempts: 10,
+		BaseDelay:   time.Second,
+		MaxDelay:    10 * time.Minute,
+	}
+}
+
+// delay 第attempt次重试前的等待时间，规则同 db.Backoff
+func (b Backoff) delay(attempt int) time.Duration {
+	return db.Backoff(b.BaseDelay, b.MaxDelay, attempt)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent 包装不可重试的错误，Publisher返回后事件直接标记为 StatusFailed
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Relay 轮询发件箱并投递事件。多个实例可同时运行，每批事件通过lease_token领取，
+// 领取后LeaseTimeout内未完成的事件(例如进程退出)会被重新领取
+type Relay struct {
+	outbox     *Outbox
+	mutex      sync.RWMutex
+	publishers map[string]Publisher
+
+	// Default 没有单独注册的topic使用的Publisher，为nil时这些事件按投递失败处理
+	Default Publisher
+	// Interval 轮询间隔，为0时使用 DefaultInterval
+	Interval time.Duration
+	// BatchSize 每次领取的事件数，为0时使用 DefaultBatchSize
+	BatchSize int
+	// LeaseTimeout 领取后的租期，为0时使用 DefaultLeaseTimeout
+	LeaseTimeout time.Duration
+	// PublishTimeout 单次投递的超时，为0时不限
+	PublishTimeout time.Duration
+	// Retry 投递失败后的重试策略，零值表示无限重试且不等待
+	Retry Backoff
+
+	started  bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewRelay 使用默认配置创建Relay
+func NewRelay(o *Outbox) *Relay {
+	return &Relay{
+		outbox:         o,
+		Interval:       DefaultInterval,
+		BatchSize:      DefaultBatchSize,
+		LeaseTimeout:   DefaultLeaseTimeout,
+		PublishTimeout: DefaultPublishTimeout,
+		Retry:          DefaultBackoff(),
+	}
+}
+
+// Handle 注册topic的Publisher
+func (r *Relay) Handle(topic string, p Publisher) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.publishers == nil {
+		r.publishers = map[string]Publisher{}
+	}
+	r.publishers[topic] = p
+}
+
+// HandleFunc 注册进程内的处理函数
+func (r *Relay) HandleFunc(topic string, f func(ctx context.Context, e *Event) error) {
+	r.Handle(topic, PublisherFunc(f))
+}
+
+// Start 启动后台轮询，重复调用或 Stop 之后调用不会生效
+func (r *Relay) Start() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.started {
+		return
+	}
+	r.started = true
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.loop(r.stop, r.done)
+}
+
+// Stop 停止轮询并等待当前批次投递完成，未启动时直接返回
+func (r *Relay) Stop() {
+	r.mutex.Lock()
+	r.started = true
+	stop, done := r.stop, r.done
+	r.mutex.Unlock()
+	if stop == nil {
+		return
+	}
+	r.stopOnce.Do(func() {
+		close(stop)
+	})
+	<-done
+}
+
+func (r *Relay) loop(stop, done chan struct{}) {
+	defer close(done)
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// 一批处理满时说明可能还有积压，不等待直接继续
+		n, err := r.RunOnce(context.Background())
+		if err != nil {
+			xlog.ErrorP(err)
+		}
+		if err != nil || n < r.batchSize() {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			continue
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+func (r *Relay) batchSize() int {
+	if r.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return r.BatchSize
+}
+
+func (r *Relay) leaseTimeout() time.Duration {
+	if r.LeaseTimeout <= 0 {
+		return DefaultLeaseTimeout
+	}
+	return r.LeaseTimeout
+}
+
+// RunOnce 领取并投递一批到期的事件，返回领取的事件数
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	if r.outbox == nil {
+		return 0, errors.New("outbox: Relay has no Outbox, use NewRelay")
+	}
+	token, err := newToken()
+	if err != nil {
+		return 0, err
+	}
+	table := r.outbox.table()
+	d := r.outbox.db
+	_, err = d.ExecContext(ctx, "UPDATE "+table+" SET lease_token = ?, next_attempt_at = NOW(3) + INTERVAL ? MICROSECOND"+
+		" WHERE status = ? AND next_attempt_at <= NOW(3) ORDER BY next_attempt_at, id LIMIT ?",
+		token, r.leaseTimeout().Microseconds(), StatusPending, r.batchSize())
+	if err != nil {
+		return 0, err
+	}
+	rows, err := d.QueryContext(db.WithPrimary(ctx), "SELECT id, topic, event_key, payload, attempts,"+
+		" CAST(ROUND(UNIX_TIMESTAMP(created_at) * 1000) AS SIGNED) FROM "+table+
+		" WHERE lease_token = ? AND status = ? ORDER BY id", token, StatusPending)
+	if err != nil {
+		return 0, err
+	}
+	var events []*Event
+	for rows.Next() {
+		var e Event
+		var created int64
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Key, &e.Payload, &e.Attempts, &created); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		e.CreatedAt = time.UnixMilli(created)
+		events = append(events, &e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, e := range events {
+		if err := r.deliver(ctx, token, e); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// deliver 投递单个事件并记录结果，只有记录结果失败时返回错误
+func (r *Relay) deliver(ctx context.Context, token string, e *Event) error {
+	table := r.outbox.table()
+	d := r.outbox.db
+	pubErr := r.publish(ctx, e)
+	e.Attempts++
+	if pubErr == nil {
+		_, err := d.ExecContext(ctx, "UPDATE "+table+" SET status = ?, attempts = ?, delivered_at = NOW(3), lease_token = '', last_error = NULL"+
+			" WHERE id = ? AND lease_token = ?", StatusDelivered, e.Attempts, e.ID, token)
+		return err
+	}
+
+	msg := pubErr.Error()
+	if len(msg) > maxErrorLen {
+		// 按字符截断，避免写入不完整的UTF-8
+		n := maxErrorLen
+		for n > 0 && !utf8.RuneStart(msg[n]) {
+			n--
+		}
+		msg = msg[:n]
+	}
+	if !r.retryable(pubErr, e.Attempts) {
+		xlog.Warnf("outbox event %d (%s) failed after %d attempts: %v", e.ID, e.Topic, e.Attempts, pubErr)
+		_, err := d.ExecContext(ctx, "UPDATE "+table+" SET status = ?, attempts = ?, lease_token = '', last_error = ?"+
+			" WHERE id = ? AND lease_token = ?", StatusFailed, e.Attempts, msg, e.ID, token)
+		return err
+	}
+	delay := r.Retry.delay(e.Attempts)
+	_, err := d.ExecContext(ctx, "UPDATE "+table+" SET attempts = ?, next_attempt_at = NOW(3) + INTERVAL ? MICROSECOND, lease_token = '', last_error = ?"+
+		" WHERE id = ? AND lease_token = ?", e.Attempts, delay.Microseconds(), msg, e.ID, token)
+	return err
+}
+
+func (r *Relay) publish(ctx context.Context, e *Event) (err error) {
+	r.mutex.RLock()
+	p, ok := r.publishers[e.Topic]
+	r.mutex.RUnlock()
+	if !ok {
+		p = r.Default
+	}
+	if p == nil {
+		return fmt.Errorf("outbox: no publisher for topic %q", e.Topic)
+	}
+	if r.PublishTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.PublishTimeout)
+		defer cancel()
+	}
+	// 处理函数panic时按投递失败处理，避免relay退出
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("outbox: publisher panic: %v", v)
+		}
+	}()
+	return p.Publish(ctx, e)
+}
+
+func (r *Relay) retryable(err error, attempts int) bool {
+	var pe *permanentError
+	if errors.As(err, &pe) {
+		return false
+	}
+	return r.Retry.MaxAttempts <= 0 || attempts < r.Retry.MaxAttempts
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
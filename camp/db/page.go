@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/mufe/golang-base/camp/util"
+)
+
+// DefaultPageSize Page.Size未设置时的每页条数
+var DefaultPageSize int64 = 20
+
+// Page 分页参数。Query为不带LIMIT的查询语句，OFFSET分页时应自带ORDER BY：
+//
+//	p := db.Page{Query: "SELECT id, name FROM user WHERE status = ? ORDER BY id", Args: []interface{}{1}, Page: 2, Size: 20}
+//	var users []User
+//	res, err := d.Paginate(ctx, &users, p)
+//
+// 设置KeyColumn后使用游标分页，After为上一页最后一行的KeyColumn值(首页为nil)，此时Page只用于填写返回的Current
+type Page struct {
+	Query string
+	Args  []interface{}
+	// CountQuery 统计总数的语句，为空时使用 SELECT COUNT(*) FROM (Query) AS _page
+	CountQuery string
+	// CountArgs CountQuery的参数，为nil时使用Args
+	CountArgs []interface{}
+	// Page 页码，从1开始
+	Page int64
+	Size int64
+	// KeyColumn 游标分页的列，须在Query的结果列中且唯一
+	KeyColumn string
+	After     interface{}
+	// Desc 游标分页按KeyColumn倒序
+	Desc bool
+	// Snapshot 在同一个可重复读的只读事务(主库)中执行统计和分页查询，保证总数与列表一致。
+	// 为false时两条查询走同一个连接池(可能是从库)，但不是同一个快照，期间的写入可能导致二者不一致
+	Snapshot bool
+}
+
+type pageQueryer interface {
+	queryer
+	QueryRowContext(ctx context.Context, sql string, args ...interface{}) *sql.Row
+}
+
+// Paginate 执行统计和分页查询，结果写入dest(规则同 Select)，并返回包含总数、当前页和列表的 util.BaseResult。
+// ctx来自本Db开启的事务的 Tx.Context() 时在该事务中执行，忽略Snapshot
+func (s *Db) Paginate(ctx context.Context, dest interface{}, p Page) (util.BaseResult, error) {
+	if tx := txFromContext(ctx, s); tx != nil {
+		return paginate(ctx, tx, dest, p)
+	}
+	if !p.Snapshot {
+		// 统计和分页查询走同一个连接池，避免轮询到复制进度不同的从库
+		return paginate(s.pinReader(ctx), s, dest, p)
+	}
+	var result util.BaseResult
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	err := s.WithTransactionContext(ctx, opts, func(tx *Tx) error {
+		var err error
+		result, err = paginate(tx.Context(), tx, dest, p)
+		return err
+	})
+	return result, err
+}
+
+// Paginate 同 Db.Paginate，在当前事务中执行，忽略Snapshot
+func (s *Tx) Paginate(ctx context.Context, dest interface{}, p Page) (util.BaseResult, error) {
+	return paginate(ctx, s, dest, p)
+}
+
+func paginate(ctx context.Context, q pageQueryer, dest interface{}, p Page) (util.BaseResult, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return util.BaseResult{}, fmt.Errorf("db: Paginate(non-slice pointer %T)", dest)
+	}
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Size < 1 {
+		p.Size = DefaultPageSize
+	}
+	countQuery, countArgs := p.CountQuery, p.CountArgs
+	if countQuery == "" {
+		countQuery = "SELECT COUNT(*) FROM (" + p.Query + ") AS _page"
+	}
+	if countArgs == nil {
+		countArgs = p.Args
+	}
+	var total int64
+	if err := q.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return util.BaseResult{}, err
+	}
+	slice := v.Elem()
+	slice.Set(reflect.MakeSlice(slice.Type(), 0, 0))
+	if total > 0 {
+		query, args := p.pageQuery()
+		if err := selectContext(ctx, q, dest, query, args...); err != nil {
+			return util.BaseResult{}, err
+		}
+	}
+	list := make([]interface{}, slice.Len())
+	for i := range list {
+		list[i] = slice.Index(i).Interface()
+	}
+	return util.CreateListCurrentResultReturn(total, p.Page, list), nil
+}
+
+// pageQuery 返回取当前页的语句和参数
+func (p Page) pageQuery() (string, []interface{}) {
+	args := make([]interface{}, 0, len(p.Args)+2)
+	args = append(args, p.Args...)
+	if p.KeyColumn == "" {
+		args = append(args, p.Size, (p.Page-1)*p.Size)
+		return p.Query + " LIMIT ? OFFSET ?", args
+	}
+	key := "_page." + QuoteIdentifier(p.KeyColumn)
+	op, order := ">", "ASC"
+	if p.Desc {
+		op, order = "<", "DESC"
+	}
+	query := "SELECT * FROM (" + p.Query + ") AS _page"
+	if p.After != nil {
+		query += " WHERE " + key + " " + op + " ?"
+		args = append(args, p.After)
+	}
+	args = append(args, p.Size)
+	return query + " ORDER BY " + key + " " + order + " LIMIT ?", args
+}
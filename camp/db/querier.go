@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/mufe/golang-base/camp/util"
+)
+
+// Querier Db 和 Tx 共同的方法，仓储层依赖该接口即可同时接收 *Db 和 *Tx，
+// 测试时可配合 dbtest 包的假驱动使用
+type Querier interface {
+	Query(sql string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(sql string, args ...interface{}) *sql.Row
+	Exec(sql string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, sql string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, sql string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, sql string, args ...interface{}) (sql.Result, error)
+
+	Select(ctx context.Context, dest interface{}, sql string, args ...interface{}) error
+	Get(ctx context.Context, dest interface{}, sql string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sql.Rows, error)
+	BulkInsert(ctx context.Context, b BulkInsert) (int64, error)
+	Paginate(ctx context.Context, dest interface{}, p Page) (util.BaseResult, error)
+
+	// WithTransaction 在 Db 上开启事务，在 Tx 上创建SAVEPOINT
+	WithTransaction(f func(tx *Tx) error) error
+}
+
+var (
+	_ Querier = (*Db)(nil)
+	_ Querier = (*Tx)(nil)
+)
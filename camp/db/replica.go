@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mufe/golang-base/camp/xlog"
+)
+
+// ReplicaPolicy 从库选择策略
+type ReplicaPolicy int
+
+const (
+	// RoundRobin 在健康的从库间轮询
+	RoundRobin ReplicaPolicy = iota
+	// Weighted 按 ReplicaConfig.Weight 平滑加权轮询
+	Weighted
+)
+
+const defaultHealthCheckInterval = 5 * time.Second
+
+// ReplicaConfig 从库配置
+type ReplicaConfig struct {
+	Config
+	// Weight 权重，仅 Weighted 策略使用，<=0 时按1处理
+	Weight int
+}
+
+// ReplicaOptions 读写分离配置
+type ReplicaOptions struct {
+	Policy ReplicaPolicy
+	// HealthCheckInterval 从库探活间隔，为0时使用默认的5秒
+	HealthCheckInterval time.Duration
+	// PingTimeout 单次探活超时，为0时等于 HealthCheckInterval
+	PingTimeout time.Duration
+}
+
+type primaryKey struct{}
+
+// WithPrimary 返回强制走主库的context，用于写后立即读的场景
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func isForcePrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	weight  int
+	current int
+	healthy int32
+}
+
+type replicaSet struct {
+	policy   ReplicaPolicy
+	replicas []*replica
+	next     uint64
+	mutex    sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// ConnectWithReplicas 连接一主多从，查询路由到从库，写入和事务走主库
+func (s *Db) ConnectWithReplicas(primary Config, replicas []ReplicaConfig, opts ReplicaOptions) error {
+	if err := s.ConnectWithConfig(primary); err != nil {
+		return err
+	}
+	set := &replicaSet{
+		policy: opts.Policy,
+		stop:   make(chan struct{}),
+	}
+	for _, cfg := range replicas {
+		pool, err := s.openPool(cfg.Config)
+		if err != nil {
+			set.close()
+			s.db.Close()
+			return err
+		}
+		weight := cfg.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		set.replicas = append(set.replicas, &replica{
+			name:    cfg.Host,
+			db:      pool,
+			weight:  weight,
+			healthy: 1,
+		})
+	}
+	s.replicas = set
+
+	interval := opts.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	timeout := opts.PingTimeout
+	if timeout <= 0 {
+		timeout = interval
+	}
+	go set.healthCheck(interval, timeout)
+	return nil
+}
+
+type pinnedReaderKey struct{}
+
+type pinnedReader struct {
+	db   *Db
+	pool *sql.DB
+}
+
+// pinReader 选定一个连接池并记录在ctx中，之后该Db用此ctx的查询都走同一个连接池
+func (s *Db) pinReader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pinnedReaderKey{}, pinnedReader{db: s, pool: s.reader(ctx)})
+}
+
+// reader 返回查询使用的连接池，没有可用从库时回退到主库
+func (s *Db) reader(ctx context.Context) *sql.DB {
+	if p, ok := ctx.Value(pinnedReaderKey{}).(pinnedReader); ok && p.db == s {
+		return p.pool
+	}
+	if s.replicas == nil || isForcePrimary(ctx) {
+		return s.db
+	}
+	if r := s.replicas.pick(); r != nil {
+		return r.db
+	}
+	return s.db
+}
+
+func (rs *replicaSet) pick() *replica {
+	switch rs.policy {
+	case Weighted:
+		return rs.pickWeighted()
+	default:
+		return rs.pickRoundRobin()
+	}
+}
+
+func (rs *replicaSet) pickRoundRobin() *replica {
+	n := len(rs.replicas)
+	if n == 0 {
+		return nil
+	}
+	start := atomic.AddUint64(&rs.next, 1)
+	for i := 0; i < n; i++ {
+		r := rs.replicas[(start+uint64(i))%uint64(n)]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r
+		}
+	}
+	return nil
+}
+
+// pickWeighted 平滑加权轮询，只在健康的从库间选择
+func (rs *replicaSet) pickWeighted() *replica {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	var (
+		best  *replica
+		total int
+	)
+	for _, r := range rs.replicas {
+		if atomic.LoadInt32(&r.healthy) != 1 {
+			continue
+		}
+		r.current += r.weight
+		total += r.weight
+		if best == nil || r.current > best.current {
+			best = r
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+func (rs *replicaSet) healthCheck(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			for _, r := range rs.replicas {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				err := r.db.PingContext(ctx)
+				cancel()
+				if err != nil {
+					if atomic.SwapInt32(&r.healthy, 0) == 1 {
+						xlog.Warnf("replica %s ejected: %v", r.name, err)
+					}
+				} else if atomic.SwapInt32(&r.healthy, 1) == 0 {
+					xlog.Infof("replica %s recovered", r.name)
+				}
+			}
+		}
+	}
+}
+
+func (rs *replicaSet) close() {
+	rs.stopOnce.Do(func() {
+		close(rs.stop)
+	})
+	for _, r := range rs.replicas {
+		r.db.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mufe/golang-base/camp/xlog"
+)
+
+// 可重试的mysql错误码
+const (
+	ErrLockWaitTimeout uint16 = 1205
+	ErrLockDeadlock    uint16 = 1213
+)
+
+// RetryPolicy 事务重试策略
+type RetryPolicy struct {
+	// MaxAttempts 最多执行次数（含第一次），<=1 时不重试
+	MaxAttempts int
+	// BaseDelay 第一次重试前的退避上限，之后每次翻倍
+	BaseDelay time.Duration
+	// MaxDelay 退避上限
+	MaxDelay time.Duration
+	// Retryable 判断错误是否可重试，为nil时使用 IsRetryableError
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy 默认策略：最多执行3次，第n次重试前随机等待0到50ms×2^(n-1)，上限1s
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   50 * time.Millisecond,
+		MaxDelay:    time.Second,
+	}
+}
+
+// IsRetryableError 是否为死锁或锁等待超时
+func IsRetryableError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == ErrLockDeadlock || mysqlErr.Number == ErrLockWaitTimeout
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryableError(err)
+}
+
+// backoff 第attempt次重试前的等待时间
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	return Backoff(p.BaseDelay, p.MaxDelay, attempt)
+}
+
+// Backoff 第attempt(从1开始)次重试前的等待时间，使用full jitter：在0到base×2^(attempt-1)之间随机，
+// 不超过max(为0时不限)；base<=0 时不等待
+func Backoff(base, max time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base << uint(attempt-1)
+	if d <= 0 || (max > 0 && d > max) {
+		d = max
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// WithTransactionRetry 按policy执行事务，可重试的错误会在新事务中重新执行f，
+// 因此f必须可以安全地重复执行
+func (s *Db) WithTransactionRetry(ctx context.Context, opts *sql.TxOptions, policy *RetryPolicy, f func(tx *Tx) error) error {
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = s.withTransaction(ctx, opts, f)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+			return err
+		}
+		delay := policy.backoff(attempt)
+		xlog.Warnf("transaction attempt %d/%d failed, retry in %v: %v", attempt, policy.MaxAttempts, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
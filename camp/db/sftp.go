@@ -0,0 +1,221 @@
+package db
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/sftp"
+)
+
+// ProgressFunc is called while a file is copied with the number of bytes written so far
+// and the file size.
+type ProgressFunc func(file string, written, total int64)
+
+type transferOptions struct {
+	preserve bool
+	progress ProgressFunc
+}
+
+// TransferOption configures Upload and Download.
+type TransferOption func(o *transferOptions)
+
+// WithPreserve keeps the permission bits and modification time of copied files.
+func WithPreserve() TransferOption {
+	return func(o *transferOptions) {
+		o.preserve = true
+	}
+}
+
+// WithProgress reports the progress of every copied file.
+func WithProgress(progress ProgressFunc) TransferOption {
+	return func(o *transferOptions) {
+		o.progress = progress
+	}
+}
+
+// SFTP opens a sftp session on the existing ssh connection. The caller must close it.
+func (c *Client) SFTP() (*sftp.Client, error) {
+	return sftp.NewClient(c.sshClient())
+}
+
+// Upload copies localPath to remotePath. Directories are copied recursively.
+func (c *Client) Upload(localPath, remotePath string, opts ...TransferOption) error {
+	o := newTransferOptions(opts)
+	client, err := c.SFTP()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return uploadFile(client, localPath, remotePath, info, o)
+	}
+	return filepath.Walk(localPath, func(local string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localPath, local)
+		if err != nil {
+			return err
+		}
+		remote := path.Join(remotePath, filepath.ToSlash(rel))
+		if info.IsDir() {
+			if err := client.MkdirAll(remote); err != nil {
+				return err
+			}
+			if o.preserve {
+				return client.Chmod(remote, info.Mode().Perm())
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return uploadFile(client, local, remote, info, o)
+	})
+}
+
+// Download copies remotePath to localPath. Directories are copied recursively.
+func (c *Client) Download(remotePath, localPath string, opts ...TransferOption) error {
+	o := newTransferOptions(opts)
+	client, err := c.SFTP()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	info, err := client.Stat(remotePath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return downloadFile(client, remotePath, localPath, info, o)
+	}
+	walker := client.Walk(remotePath)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		remote, info := walker.Path(), walker.Stat()
+		rel, err := filepath.Rel(remotePath, remote)
+		if err != nil {
+			return err
+		}
+		local := filepath.Join(localPath, rel)
+		if info.IsDir() {
+			if err := os.MkdirAll(local, 0755); err != nil {
+				return err
+			}
+			if o.preserve {
+				if err := os.Chmod(local, info.Mode().Perm()); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if err := downloadFile(client, remote, local, info, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTransferOptions(opts []TransferOption) *transferOptions {
+	o := &transferOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func uploadFile(client *sftp.Client, local, remote string, info os.FileInfo, o *transferOptions) error {
+	src, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := client.MkdirAll(path.Dir(remote)); err != nil {
+		return err
+	}
+	dst, err := client.Create(remote)
+	if err != nil {
+		return err
+	}
+	if err := copyWithProgress(dst, src, local, info.Size(), o.progress); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if o.preserve {
+		if err := client.Chmod(remote, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return client.Chtimes(remote, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+func downloadFile(client *sftp.Client, remote, local string, info os.FileInfo, o *transferOptions) error {
+	src, err := client.Open(remote)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return err
+	}
+	dst, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+	if err := copyWithProgress(dst, src, remote, info.Size(), o.progress); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if o.preserve {
+		if err := os.Chmod(local, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(local, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+func copyWithProgress(dst io.Writer, src io.Reader, file string, total int64, progress ProgressFunc) error {
+	if progress != nil {
+		dst = &progressWriter{w: dst, file: file, total: total, progress: progress}
+	}
+	_, err := io.Copy(dst, src)
+	return err
+}
+
+type progressWriter struct {
+	w        io.Writer
+	file     string
+	written  int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.progress(p.file, p.written, p.total)
+	return n, err
+}
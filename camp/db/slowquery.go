@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mufe/golang-base/camp/sqlfmt"
+	"github.com/mufe/golang-base/camp/xlog"
+)
+
+const (
+	defaultExplainTimeout = 5 * time.Second
+	slowQueryQueueSize    = 100
+)
+
+// SlowQueryConfig 慢查询分析配置
+type SlowQueryConfig struct {
+	// Threshold 慢查询阈值，为0时使用全局的 SlowThreshold；通过 LogConfig.SlowQuery 配置时使用该Db的慢查询阈值
+	Threshold time.Duration
+	// Output 慢查询记录的输出，每条记录一行JSON，为nil时写入xlog
+	Output io.Writer
+	// ExplainTimeout 单次EXPLAIN的超时，为0时为5秒
+	ExplainTimeout time.Duration
+	// Formatter 记录中嵌入参数使用的格式，为nil时使用 sqlfmt.Default，
+	// Db 会按连接的 Loc 自动设置
+	Formatter *sqlfmt.Formatter
+}
+
+// SlowQueryRecord 慢查询记录
+type SlowQueryRecord struct {
+	Time          time.Time     `json:"time"`
+	Fingerprint   string        `json:"fingerprint"`
+	FingerprintID string        `json:"fingerprint_id"`
+	Query         string        `json:"query"`
+	Duration      time.Duration `json:"duration"`
+	TxID          uint64        `json:"tx_id,omitempty"`
+	Plan          *PlanSummary  `json:"plan,omitempty"`
+	ExplainError  string        `json:"explain_error,omitempty"`
+}
+
+// PlanSummary EXPLAIN FORMAT=JSON 的摘要
+type PlanSummary struct {
+	QueryCost      string   `json:"query_cost,omitempty"`
+	FullScanTables []string `json:"full_scan_tables,omitempty"`
+	Filesort       bool     `json:"filesort"`
+	TemporaryTable bool     `json:"temporary_table"`
+	RowsExamined   int64    `json:"rows_examined"`
+}
+
+// SlowQueryAnalyzer 慢查询分析钩子，EXPLAIN 在后台使用独立连接执行，不会阻塞原查询
+type SlowQueryAnalyzer struct {
+	db     *sql.DB
+	cfg    SlowQueryConfig
+	queue  chan *QueryEvent
+	mutex  sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// NewSlowQueryAnalyzer explainDB 用于执行EXPLAIN，不应使用日志驱动，Close 时一并关闭
+func NewSlowQueryAnalyzer(explainDB *sql.DB, cfg SlowQueryConfig) *SlowQueryAnalyzer {
+	if cfg.ExplainTimeout <= 0 {
+		cfg.ExplainTimeout = defaultExplainTimeout
+	}
+	if cfg.Formatter == nil {
+		cfg.Formatter = sqlfmt.Default
+	}
+	a := &SlowQueryAnalyzer{
+		db:    explainDB,
+		cfg:   cfg,
+		queue: make(chan *QueryEvent, slowQueryQueueSize),
+		done:  make(chan struct{}),
+	}
+	go a.loop()
+	return a
+}
+
+func (a *SlowQueryAnalyzer) Before(ctx context.Context, e *QueryEvent) context.Context {
+	return ctx
+}
+
+func (a *SlowQueryAnalyzer) After(ctx context.Context, e *QueryEvent) {
+	threshold := a.cfg.Threshold
+	if threshold <= 0 {
+		threshold = SlowThreshold
+	}
+	if e.Err != nil || e.Duration <= threshold || !isSelect(e.Query) {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.queue <- e:
+	default:
+		// 队列已满时丢弃，避免拖慢业务查询
+	}
+}
+
+// Close 停止分析并关闭EXPLAIN连接池
+func (a *SlowQueryAnalyzer) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.mutex.Unlock()
+	<-a.done
+	return a.db.Close()
+}
+
+func (a *SlowQueryAnalyzer) loop() {
+	defer close(a.done)
+	for e := range a.queue {
+		a.write(a.analyze(e))
+	}
+}
+
+func (a *SlowQueryAnalyzer) analyze(e *QueryEvent) *SlowQueryRecord {
+	fingerprint := Fingerprint(e.Query)
+	sum := sha1.Sum([]byte(fingerprint))
+	record := &SlowQueryRecord{
+		Time:          e.Start,
+		Fingerprint:   fingerprint,
+		FingerprintID: hex.EncodeToString(sum[:8]),
+		Query:         a.cfg.Formatter.Interpolate(e.Query, e.Args...),
+		Duration:      e.Duration,
+		TxID:          e.TxID,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ExplainTimeout)
+	defer cancel()
+	var plan string
+	err := a.db.QueryRowContext(ctx, "EXPLAIN FORMAT=JSON "+e.Query, e.Args...).Scan(&plan)
+	if err != nil {
+		record.ExplainError = err.Error()
+		return record
+	}
+	record.Plan, err = summarizePlan(plan)
+	if err != nil {
+		record.ExplainError = err.Error()
+	}
+	return record
+}
+
+func (a *SlowQueryAnalyzer) write(record *SlowQueryRecord) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		xlog.ErrorP(err)
+		return
+	}
+	if a.cfg.Output == nil {
+		xlog.Warn("[SLOW QUERY]", string(b))
+		return
+	}
+	if _, err := a.cfg.Output.Write(append(b, '\n')); err != nil {
+		xlog.ErrorP(err)
+	}
+}
+
+// isSelect 跳过开头的注释和括号后，判断语句是否以 SELECT 或 WITH 开头
+func isSelect(query string) bool {
+	for _, t := range sqlfmt.Tokenize(query) {
+		if t.Kind == sqlfmt.Comment {
+			continue
+		}
+		if t.Kind != sqlfmt.Text {
+			return false
+		}
+		text := strings.TrimLeft(t.Text, " \t\r\n(")
+		if text == "" {
+			continue
+		}
+		return hasKeyword(text, "SELECT") || hasKeyword(text, "WITH")
+	}
+	return false
+}
+
+// hasKeyword text是否以关键字kw开头且kw之后不是标识符字符
+func hasKeyword(text, kw string) bool {
+	if len(text) < len(kw) || !strings.EqualFold(text[:len(kw)], kw) {
+		return false
+	}
+	if len(text) == len(kw) {
+		return true
+	}
+	c := text[len(kw)]
+	return !(c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z')
+}
+
+// summarizePlan 遍历 EXPLAIN FORMAT=JSON 的结果，找出全表扫描、filesort 和临时表
+func summarizePlan(plan string) (*PlanSummary, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal([]byte(plan), &root); err != nil {
+		return nil, err
+	}
+	summary := &PlanSummary{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if b, _ := v["using_filesort"].(bool); b {
+				summary.Filesort = true
+			}
+			if b, _ := v["using_temporary_table"].(bool); b {
+				summary.TemporaryTable = true
+			}
+			if cost, ok := v["cost_info"].(map[string]interface{}); ok && summary.QueryCost == "" {
+				summary.QueryCost, _ = cost["query_cost"].(string)
+			}
+			if name, ok := v["table_name"].(string); ok {
+				if access, _ := v["access_type"].(string); access == "ALL" {
+					summary.FullScanTables = append(summary.FullScanTables, name)
+				}
+				if rows, ok := v["rows_examined_per_scan"].(float64); ok {
+					summary.RowsExamined += int64(rows)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+	return summary, nil
+}
+
+var (
+	fingerprintSpaces = regexp.MustCompile(`\s+`)
+	fingerprintNumber = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+	fingerprintInList = regexp.MustCompile(`\(\s*\?(\s*,\s*\?)*\s*\)`)
+	fingerprintString = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"`)
+)
+
+// Fingerprint 将语句中的常量替换为 ?，IN 列表合并为 (?+)，用于归并同类慢查询
+func Fingerprint(query string) string {
+	fp := fingerprintString.ReplaceAllString(query, "?")
+	fp = fingerprintNumber.ReplaceAllString(fp, "?")
+	fp = fingerprintSpaces.ReplaceAllString(strings.TrimSpace(fp), " ")
+	fp = fingerprintInList.ReplaceAllString(fp, "(?+)")
+	return strings.ToLower(fp)
+}
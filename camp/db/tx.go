@@ -1,32 +1,125 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/mufe/golang-base/camp/xlog"
+	"strconv"
+	"sync/atomic"
 )
 
 type Tx struct {
-	tx    *sql.Tx
+	tx  *sql.Tx
+	ctx context.Context
+	// db 开启事务的Db，ctx中的事务只会被同一个Db加入
+	db *Db
+	// Print 同 Db.Print，语句日志由连接池的钩子输出
 	Print bool
+	// depth 嵌套层级，0为最外层事务
+	depth int
+	// savepoints 同一事务内的SAVEPOINT计数，用于生成唯一名称
+	savepoints *int32
+}
+
+type txKey struct{}
+
+// txFromContext 返回ctx中由d开启的事务，其他Db开启的事务不会被使用，避免写入错误的数据库
+func txFromContext(ctx context.Context, d *Db) *Tx {
+	t, _ := ctx.Value(txKey{}).(*Tx)
+	if t == nil || t.db != d {
+		return nil
+	}
+	return t
 }
 
 func (s *Tx) Query(sql string, args ...interface{}) (rows *sql.Rows, err error) {
-	rows, err = s.tx.Query(sql, args...)
+	return s.QueryContext(s.Context(), sql, args...)
+}
+
+func (s *Tx) QueryRow(sql string, args ...interface{}) (result *sql.Row) {
+	return s.QueryRowContext(s.Context(), sql, args...)
+}
+
+func (s *Tx) Exec(sql string, args ...interface{}) (result sql.Result, err error) {
+	return s.ExecContext(s.Context(), sql, args...)
+}
+
+// QueryContext 带context的查询
+func (s *Tx) QueryContext(ctx context.Context, sql string, args ...interface{}) (rows *sql.Rows, err error) {
+	rows, err = s.tx.QueryContext(ctx, sql, args...)
 	if err != nil {
 		xlog.ErrorP(err)
 	}
 	return rows, err
 }
 
-func (s *Tx) QueryRow(sql string, args ...interface{}) (result *sql.Row) {
-	result = s.tx.QueryRow(sql, args...)
-	return result
+// QueryRowContext 带context的单行查询
+func (s *Tx) QueryRowContext(ctx context.Context, sql string, args ...interface{}) (result *sql.Row) {
+	return s.tx.QueryRowContext(ctx, sql, args...)
 }
 
-func (s *Tx) Exec(sql string, args ...interface{}) (result sql.Result, err error) {
-	result, err = s.tx.Exec(sql, args...)
-	return result, err
+// ExecContext 带context的执行
+func (s *Tx) ExecContext(ctx context.Context, sql string, args ...interface{}) (result sql.Result, err error) {
+	return s.tx.ExecContext(ctx, sql, args...)
+}
+
+// Context 返回开启事务时传入的context
+func (s *Tx) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// WithTransaction 在当前事务中开启嵌套事务，f返回错误或panic时回滚到SAVEPOINT，否则释放SAVEPOINT
+func (s *Tx) WithTransaction(f func(tx *Tx) error) error {
+	return s.WithTransactionContext(s.Context(), f)
+}
+
+// WithTransactionContext 同 WithTransaction，ctx用于执行SAVEPOINT相关语句
+func (s *Tx) WithTransactionContext(ctx context.Context, f func(tx *Tx) error) error {
+	if s.savepoints == nil {
+		s.savepoints = new(int32)
+	}
+	name := "sp_" + strconv.Itoa(int(atomic.AddInt32(s.savepoints, 1)))
+	var t = &Tx{
+		tx:         s.tx,
+		db:         s.db,
+		Print:      s.Print,
+		depth:      s.depth + 1,
+		savepoints: s.savepoints,
+	}
+	t.ctx = context.WithValue(ctx, txKey{}, t)
+	_, err := t.ExecContext(ctx, "SAVEPOINT "+name)
+	if err != nil {
+		xlog.ErrorP(err)
+		return err
+	}
+	// 调用f时如果出现panic，err则会无法正常赋值，因此需要此变量
+	var success bool
+	defer func() {
+		if !success {
+			t.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		}
+	}()
+	err = f(t)
+	if err != nil {
+		xlog.ErrorP(err)
+		return err
+	}
+	_, err = t.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	if err != nil {
+		xlog.ErrorP(err)
+		return err
+	}
+	success = true
+	return nil
+}
+
+// Depth 返回嵌套层级，最外层事务为0
+func (s *Tx) Depth() int {
+	return s.depth
 }
 
 func (s *Tx) GetTx() *sql.Tx {
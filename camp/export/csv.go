@@ -0,0 +1,37 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// utf8BOM 让Excel以UTF-8打开CSV
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+type csvWriter struct {
+	w      *csv.Writer
+	layout string
+	record []string
+}
+
+func newCSVWriter(w io.Writer, bom bool, layout string) (*csvWriter, error) {
+	if bom {
+		if _, err := w.Write(utf8BOM); err != nil {
+			return nil, err
+		}
+	}
+	return &csvWriter{w: csv.NewWriter(w), layout: layout}, nil
+}
+
+func (s *csvWriter) WriteRow(values []interface{}) error {
+	s.record = s.record[:0]
+	for _, v := range values {
+		s.record = append(s.record, toString(v, s.layout))
+	}
+	return s.w.Write(s.record)
+}
+
+func (s *csvWriter) Close() error {
+	s.w.Flush()
+	return s.w.Error()
+}
@@ -0,0 +1,262 @@
+// Package export 将 *sql.Rows 逐行写出为CSV或XLSX，不会把整个结果集读入内存：
+//
+//	rows, err := d.QueryContext(ctx, "SELECT id, name, created_at FROM user")
+//	...
+//	defer rows.Close()
+//	e := &export.Exporter{
+//		Format: export.XLSX,
+//		Columns: []export.Column{
+//			{Name: "id", Header: "编号"},
+//			{Name: "name", Header: "姓名"},
+//			{Name: "created_at", Header: "注册时间", Format: export.TimeFormatter("2006-01-02")},
+//		},
+//	}
+//	err = e.WriteGin(c, "用户", rows)
+package export
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Format 导出格式
+type Format int
+
+const (
+	CSV Format = iota
+	XLSX
+)
+
+// Ext 文件扩展名
+func (f Format) Ext() string {
+	if f == XLSX {
+		return ".xlsx"
+	}
+	return ".csv"
+}
+
+// ContentType HTTP响应的Content-Type
+func (f Format) ContentType() string {
+	if f == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// DefaultTimeLayout time.Time默认的输出格式
+const DefaultTimeLayout = "2006-01-02 15:04:05"
+
+// Formatter 转换单元格的值，v为Scan得到的值(nil、[]byte、int64、float64、time.Time等)。
+// 返回数字类型时XLSX中写为数值单元格；数值列(INT、DECIMAL、DOUBLE等)中[]byte形式的值同样写为数值，其他按字符串写出
+type Formatter func(v interface{}) interface{}
+
+// TimeFormatter 按layout格式化time.Time
+func TimeFormatter(layout string) Formatter {
+	return func(v interface{}) interface{} {
+		if t, ok := v.(time.Time); ok {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(layout)
+		}
+		return v
+	}
+}
+
+// MapFormatter 将值映射为文字，例如状态码转为中文，找不到时原样输出
+func MapFormatter(m map[string]string) Formatter {
+	return func(v interface{}) interface{} {
+		if s, ok := m[toString(v, DefaultTimeLayout)]; ok {
+			return s
+		}
+		return v
+	}
+}
+
+// Column 导出的列
+type Column struct {
+	// Name 结果集中的列名
+	Name string
+	// Header 表头，为空时使用Name
+	Header string
+	Format Formatter
+}
+
+// Exporter 导出配置，可复用
+type Exporter struct {
+	Format Format
+	// Columns 导出的列及顺序，为空时按结果集的列全部导出
+	Columns []Column
+	// TimeLayout time.Time的输出格式，为空时使用 DefaultTimeLayout
+	TimeLayout string
+	// Sheet XLSX的工作表名，为空时为Sheet1
+	Sheet string
+	// NoBOM CSV不写UTF-8 BOM，默认写入以便Excel正确识别中文
+	NoBOM bool
+}
+
+// ErrTooManyRows 超过XLSX单个工作表的行数上限
+var ErrTooManyRows = errors.New("export: too many rows for xlsx sheet")
+
+// maxXLSXRows XLSX单个工作表最多的行数(含表头)
+const maxXLSXRows = 1048576
+
+type sheetWriter interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// Write 将rows剩余的行写入w，返回写出的数据行数(不含表头)。调用方负责关闭rows
+func (e *Exporter) Write(w io.Writer, rows *sql.Rows) (int64, error) {
+	names, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	columns, indexes, err := e.resolve(names)
+	if err != nil {
+		return 0, err
+	}
+	// 不带参数的查询走文本协议，数值也以[]byte返回，XLSX按列类型决定是否写为数值单元格
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+	layout := e.TimeLayout
+	if layout == "" {
+		layout = DefaultTimeLayout
+	}
+
+	bw := bufio.NewWriter(w)
+	var sw sheetWriter
+	if e.Format == XLSX {
+		numeric := make([]bool, len(columns))
+		for i := range columns {
+			numeric[i] = isNumericType(types[indexes[i]].DatabaseTypeName())
+		}
+		sw, err = newXLSXWriter(bw, e.Sheet, layout, numeric)
+	} else {
+		sw, err = newCSVWriter(bw, !e.NoBOM, layout)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = c.Header
+		if c.Header == "" {
+			header[i] = c.Name
+		}
+	}
+	if err := sw.WriteRow(header); err != nil {
+		return 0, err
+	}
+
+	// 只保存当前行，Scan到interface{}时driver的[]byte会被复制
+	values := make([]interface{}, len(names))
+	targets := make([]interface{}, len(names))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	out := make([]interface{}, len(columns))
+	var n int64
+	for rows.Next() {
+		if e.Format == XLSX && n+1 >= maxXLSXRows {
+			return n, ErrTooManyRows
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return n, err
+		}
+		for i, c := range columns {
+			v := values[indexes[i]]
+			if c.Format != nil {
+				v = c.Format(v)
+			}
+			out[i] = v
+		}
+		if err := sw.WriteRow(out); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	if err := sw.Close(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// WriteGin 设置下载相关的响应头并将rows写入gin的响应，filename不含扩展名
+func (e *Exporter) WriteGin(c *gin.Context, filename string, rows *sql.Rows) error {
+	name := filename + e.Format.Ext()
+	c.Header("Content-Type", e.Format.ContentType())
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Header("Cache-Control", "no-cache")
+	c.Status(200)
+	_, err := e.Write(c.Writer, rows)
+	return err
+}
+
+// resolve 返回导出的列以及每列在结果集中的下标
+func (e *Exporter) resolve(names []string) ([]Column, []int, error) {
+	columns := e.Columns
+	if len(columns) == 0 {
+		columns = make([]Column, len(names))
+		for i, name := range names {
+			columns[i] = Column{Name: name}
+		}
+	}
+	positions := make(map[string]int, len(names))
+	for i, name := range names {
+		positions[name] = i
+	}
+	indexes := make([]int, len(columns))
+	for i, c := range columns {
+		pos, ok := positions[c.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("export: column %q not in result set", c.Name)
+		}
+		indexes[i] = pos
+	}
+	return columns, indexes, nil
+}
+
+// toString 将单元格的值转为字符串
+func toString(v interface{}, layout string) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format(layout)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	case fmt.Stringer:
+		return x.String()
+	default:
+		return fmt.Sprint(x)
+	}
+}
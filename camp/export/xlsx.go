@@ -0,0 +1,234 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxExactInt Excel数值只有15位有效数字，超过的整数(例如雪花id)按文本写出
+const maxExactInt = 999999999999999
+
+// xlsxWriter 只包含一个工作表的最小XLSX，单元格使用内联字符串，
+// 不需要在结尾写共享字符串表，因此可以边查询边输出
+type xlsxWriter struct {
+	zw     *zip.Writer
+	sheet  io.Writer
+	layout string
+	// numeric 每列是否为数值类型的列
+	numeric []bool
+	row     int
+	buf     []byte
+}
+
+func newXLSXWriter(w io.Writer, sheet, layout string, numeric []bool) (*xlsxWriter, error) {
+	if sheet == "" {
+		sheet = "Sheet1"
+	}
+	zw := zip.NewWriter(w)
+	for _, f := range []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbookHead + xmlAttr(sheet) + xlsxWorkbookTail},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	} {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return nil, err
+		}
+	}
+	// 工作表必须是最后一个条目，之后不能再创建其他文件
+	sw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sw, xlsxSheetHead); err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{zw: zw, sheet: sw, layout: layout, numeric: numeric}, nil
+}
+
+func (s *xlsxWriter) WriteRow(values []interface{}) error {
+	s.row++
+	row := strconv.Itoa(s.row)
+	b := append(s.buf[:0], `<row r="`...)
+	b = append(b, row...)
+	b = append(b, `">`...)
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		b = append(b, `<c r="`...)
+		b = appendColumnName(b, i)
+		b = append(b, row...)
+		num, ok := numberValue(v)
+		if !ok && i < len(s.numeric) && s.numeric[i] {
+			num, ok = numberText(v)
+		}
+		if ok {
+			b = append(b, `"><v>`...)
+			b = append(b, num...)
+			b = append(b, `</v></c>`...)
+			continue
+		}
+		b = append(b, `" t="inlineStr"><is><t xml:space="preserve">`...)
+		b = appendEscaped(b, toString(v, s.layout))
+		b = append(b, `</t></is></c>`...)
+	}
+	b = append(b, `</row>`...)
+	s.buf = b
+	_, err := s.sheet.Write(b)
+	return err
+}
+
+func (s *xlsxWriter) Close() error {
+	if _, err := io.WriteString(s.sheet, xlsxSheetTail); err != nil {
+		return err
+	}
+	return s.zw.Close()
+}
+
+// numberValue 数字类型返回其文本形式
+func numberValue(v interface{}) (string, bool) {
+	switch x := v.(type) {
+	case int:
+		return intValue(int64(x))
+	case int8:
+		return intValue(int64(x))
+	case int16:
+		return intValue(int64(x))
+	case int32:
+		return intValue(int64(x))
+	case int64:
+		return intValue(x)
+	case uint:
+		return uintValue(uint64(x))
+	case uint8:
+		return uintValue(uint64(x))
+	case uint16:
+		return uintValue(uint64(x))
+	case uint32:
+		return uintValue(uint64(x))
+	case uint64:
+		return uintValue(x)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+// isNumericType mysql驱动返回的列类型名是否为数值类型
+func isNumericType(name string) bool {
+	switch strings.TrimPrefix(name, "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+		return true
+	}
+	return false
+}
+
+// numberText 数值列中文本协议返回的[]byte，超过15位有效数字时按文本写出
+func numberText(v interface{}) (string, bool) {
+	b, ok := v.([]byte)
+	if !ok || len(b) == 0 {
+		return "", false
+	}
+	s := string(b)
+	if strings.Trim(s, "0123456789+-.eE") != "" {
+		return "", false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return "", false
+	}
+	digits := strings.TrimLeft(strings.TrimLeft(s, "+-"), "0.")
+	if i := strings.IndexAny(digits, "eE"); i >= 0 {
+		digits = digits[:i]
+	}
+	if strings.Contains(digits, ".") {
+		digits = strings.TrimRight(digits, "0")
+	}
+	if len(digits)-strings.Count(digits, ".") > 15 {
+		return "", false
+	}
+	return s, true
+}
+
+func intValue(x int64) (string, bool) {
+	if x > maxExactInt || x < -maxExactInt {
+		return "", false
+	}
+	return strconv.FormatInt(x, 10), true
+}
+
+func uintValue(x uint64) (string, bool) {
+	if x > maxExactInt {
+		return "", false
+	}
+	return strconv.FormatUint(x, 10), true
+}
+
+// appendColumnName 写入列名，0为A，26为AA
+func appendColumnName(b []byte, i int) []byte {
+	var name [4]byte
+	n := len(name)
+	for i++; i > 0; i = (i - 1) / 26 {
+		n--
+		name[n] = byte('A' + (i-1)%26)
+	}
+	return append(b, name[n:]...)
+}
+
+type byteWriter struct {
+	b []byte
+}
+
+func (w *byteWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+// appendEscaped 转义xml文本，非法的控制字符会被替换
+func appendEscaped(b []byte, s string) []byte {
+	w := &byteWriter{b: b}
+	xml.EscapeText(w, []byte(s))
+	return w.b
+}
+
+func xmlAttr(s string) string {
+	return string(appendEscaped(nil, s))
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="`
+
+const xlsxWorkbookTail = `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`</Relationships>`
+
+const xlsxSheetHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const xlsxSheetTail = `</sheetData></worksheet>`
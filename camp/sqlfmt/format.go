@@ -0,0 +1,159 @@
+package sqlfmt
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Formatter 将参数按mysql语法嵌入语句，仅用于日志展示，不能用于执行
+type Formatter struct {
+	// Location time.Time 参数转换到的时区，应与连接的 loc 参数一致，为nil时使用UTC
+	Location *time.Location
+}
+
+// Default 与mysql驱动默认的 loc=UTC 一致
+var Default = &Formatter{Location: time.UTC}
+
+// Interpolate 使用 Default 替换 ? 和 $n 占位符
+func Interpolate(query string, args ...interface{}) string {
+	return Default.Interpolate(query, args...)
+}
+
+// Interpolate 替换 ? 和 $n 占位符，字符串、标识符和注释中的占位符不受影响；
+// 参数不足时保留占位符
+func (f *Formatter) Interpolate(query string, args ...interface{}) string {
+	if len(args) == 0 {
+		return query
+	}
+	var (
+		buf   strings.Builder
+		index int
+	)
+	buf.Grow(len(query))
+	for _, t := range Tokenize(query) {
+		switch t.Kind {
+		case Placeholder:
+			if index < len(args) {
+				buf.WriteString(f.Value(args[index]))
+			} else {
+				buf.WriteString(t.Text)
+			}
+			index++
+		case NumericPlaceholder:
+			if t.Index >= 1 && t.Index <= len(args) {
+				buf.WriteString(f.Value(args[t.Index-1]))
+			} else {
+				buf.WriteString(t.Text)
+			}
+		default:
+			buf.WriteString(t.Text)
+		}
+	}
+	return buf.String()
+}
+
+// Value 将单个参数格式化为mysql字面量
+func (f *Formatter) Value(arg interface{}) string {
+	if valuer, ok := arg.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(arg); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "NULL"
+		}
+		v, err := valuer.Value()
+		if err != nil {
+			return "NULL"
+		}
+		arg = v
+	}
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + Escape(v) + "'"
+	case []byte:
+		if v == nil {
+			return "NULL"
+		}
+		if !utf8.Valid(v) || !isPrintable(v) {
+			return "X'" + hex.EncodeToString(v) + "'"
+		}
+		return "'" + Escape(string(v)) + "'"
+	case time.Time:
+		return "'" + f.formatTime(v) + "'"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	rv := reflect.ValueOf(arg)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "NULL"
+		}
+		return f.Value(rv.Elem().Interface())
+	}
+	return "'" + Escape(fmt.Sprint(arg)) + "'"
+}
+
+func (f *Formatter) formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "0000-00-00"
+	}
+	loc := f.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	if t.Nanosecond() == 0 {
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return t.Format("2006-01-02 15:04:05.999999")
+}
+
+// Escape 按mysql默认（非 NO_BACKSLASH_ESCAPES）模式转义字符串
+func Escape(s string) string {
+	var buf strings.Builder
+	buf.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 0:
+			buf.WriteString(`\0`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\x1a':
+			buf.WriteString(`\Z`)
+		case '\'':
+			buf.WriteString(`\'`)
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+func isPrintable(b []byte) bool {
+	for _, r := range string(b) {
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
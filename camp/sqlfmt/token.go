@@ -0,0 +1,229 @@
+package sqlfmt
+
+import "strings"
+
+// Kind 词法单元类型
+type Kind int
+
+const (
+	// Text 普通SQL文本
+	Text Kind = iota
+	// String 单引号或双引号字符串，包含引号
+	String
+	// Identifier 反引号标识符，包含反引号
+	Identifier
+	// Comment -- 、# 或 /* */ 注释
+	Comment
+	// Placeholder ? 占位符
+	Placeholder
+	// NumericPlaceholder $1 形式的占位符
+	NumericPlaceholder
+	// Semicolon 语句分隔符
+	Semicolon
+)
+
+// Token 词法单元
+type Token struct {
+	Kind Kind
+	Text string
+	// Pos 在原语句中的字节下标
+	Pos int
+	// Index NumericPlaceholder 的序号，从1开始
+	Index int
+}
+
+// Tokenize 将语句切分为词法单元，拼接所有 Token.Text 可还原原语句
+func Tokenize(query string) []Token {
+	var (
+		tokens []Token
+		start  int
+	)
+	flush := func(end int) {
+		if end > start {
+			tokens = append(tokens, Token{Kind: Text, Text: query[start:end], Pos: start})
+		}
+	}
+	emit := func(kind Kind, i, end, index int) int {
+		flush(i)
+		tokens = append(tokens, Token{Kind: kind, Text: query[i:end], Pos: i, Index: index})
+		start = end
+		return end - 1
+	}
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"':
+			i = emit(String, i, quotedEnd(query, i, c), 0)
+		case c == '`':
+			i = emit(Identifier, i, quotedEnd(query, i, c), 0)
+		case c == '#' || isDashComment(query, i):
+			end := len(query)
+			if n := strings.IndexByte(query[i:], '\n'); n >= 0 {
+				end = i + n
+			}
+			i = emit(Comment, i, end, 0)
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := len(query)
+			if n := strings.Index(query[i+2:], "*/"); n >= 0 {
+				end = i + n + 4
+			}
+			i = emit(Comment, i, end, 0)
+		case c == '?':
+			i = emit(Placeholder, i, i+1, 0)
+		case c == ';':
+			i = emit(Semicolon, i, i+1, 0)
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]) && !isWordByte(query, i-1):
+			end, index := i+1, 0
+			for end < len(query) && isDigit(query[end]) {
+				index = index*10 + int(query[end]-'0')
+				end++
+			}
+			i = emit(NumericPlaceholder, i, end, index)
+		}
+	}
+	flush(len(query))
+	return tokens
+}
+
+// quotedEnd 返回从start开始的引号内容结束后的下标，支持反斜杠转义和重复引号转义
+func quotedEnd(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+// isDashComment mysql要求 -- 后跟空白字符或位于结尾才是注释
+func isDashComment(query string, i int) bool {
+	if !strings.HasPrefix(query[i:], "--") {
+		return false
+	}
+	if i+2 == len(query) {
+		return true
+	}
+	switch query[i+2] {
+	case ' ', '\t', '\n', '\r':
+		return true
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordByte(query string, i int) bool {
+	if i < 0 {
+		return false
+	}
+	c := query[i]
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Split 按分号拆分多条语句，去除首尾空白以及空语句和只有注释(/*! */ 除外)的语句。
+// 支持mysql客户端的 DELIMITER 命令，CREATE PROCEDURE、CREATE TRIGGER 等包含 BEGIN ... END 的语句需要先切换分隔符：
+//
+//	DELIMITER $$
+//	CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.x = 1; END$$
+//	DELIMITER ;
+//
+// 自定义分隔符只在字符串、标识符和注释之外匹配，且不能包含分号
+func Split(script string) []string {
+	var (
+		statements []string
+		delimiter  = ";"
+		// start 当前语句的起始位置，skip DELIMITER 命令结束的位置
+		start, skip int
+		hasCode     bool
+	)
+	add := func(end int) {
+		if st := strings.TrimSpace(script[start:end]); st != "" && hasCode {
+			statements = append(statements, st)
+		}
+		hasCode = false
+	}
+	for _, t := range Tokenize(script) {
+		pos, end := t.Pos, t.Pos+len(t.Text)
+		if end <= skip {
+			continue
+		}
+		if pos < skip {
+			pos = skip
+		}
+		switch t.Kind {
+		case Semicolon:
+			if delimiter == ";" {
+				add(pos)
+				start = end
+				continue
+			}
+			hasCode = true
+		case Comment:
+			// /*! ... */ 是mysql会执行的条件注释
+			if strings.HasPrefix(t.Text, "/*!") {
+				hasCode = true
+			}
+		case Text:
+			for pos < end {
+				if !hasCode {
+					if d, n := delimiterCommand(script[pos:]); n > 0 {
+						delimiter = d
+						skip = pos + n
+						start, pos = skip, skip
+						continue
+					}
+				}
+				i := -1
+				if delimiter != ";" {
+					i = strings.Index(script[pos:end], delimiter)
+				}
+				if i < 0 {
+					if strings.TrimSpace(script[pos:end]) != "" {
+						hasCode = true
+					}
+					break
+				}
+				if strings.TrimSpace(script[pos:pos+i]) != "" {
+					hasCode = true
+				}
+				add(pos + i)
+				pos += i + len(delimiter)
+				start = pos
+			}
+		default:
+			hasCode = true
+		}
+	}
+	add(len(script))
+	return statements
+}
+
+// delimiterCommand 解析s开头(可有空白)的 DELIMITER 命令，返回新的分隔符和命令占用的字节数(含换行)，
+// 不是 DELIMITER 命令时返回0
+func delimiterCommand(s string) (string, int) {
+	const keyword = "DELIMITER"
+	i := len(s) - len(strings.TrimLeft(s, " \t\r\n"))
+	end := i + len(keyword)
+	if len(s) <= end || !strings.EqualFold(s[i:end], keyword) || (s[end] != ' ' && s[end] != '\t') {
+		return "", 0
+	}
+	line, n := s[end:], len(s)
+	if j := strings.IndexByte(line, '\n'); j >= 0 {
+		line, n = line[:j], end+j+1
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || (fields[0] != ";" && strings.Contains(fields[0], ";")) {
+		return "", 0
+	}
+	return fields[0], n
+}
@@ -0,0 +1,180 @@
+package sqlfmt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type tok struct {
+	Kind  Kind
+	Text  string
+	Index int
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []tok
+	}{
+		{
+			name:  "placeholders",
+			query: "a = ? AND b = ?",
+			want:  []tok{{Text, "a = ", 0}, {Placeholder, "?", 0}, {Text, " AND b = ", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "placeholder inside single quotes",
+			query: "a = '?' AND b = ?",
+			want:  []tok{{Text, "a = ", 0}, {String, "'?'", 0}, {Text, " AND b = ", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "placeholder inside double quotes",
+			query: `a = "?"`,
+			want:  []tok{{Text, "a = ", 0}, {String, `"?"`, 0}},
+		},
+		{
+			name:  "backslash escaped quote",
+			query: `a = 'it\'s ?' AND ?`,
+			want:  []tok{{Text, "a = ", 0}, {String, `'it\'s ?'`, 0}, {Text, " AND ", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "doubled quote",
+			query: "a = 'it''s ?' AND ?",
+			want:  []tok{{Text, "a = ", 0}, {String, "'it''s ?'", 0}, {Text, " AND ", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "escaped backslash before closing quote",
+			query: `'a\\' ?`,
+			want:  []tok{{String, `'a\\'`, 0}, {Text, " ", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "unterminated string",
+			query: "a = 'x ?",
+			want:  []tok{{Text, "a = ", 0}, {String, "'x ?", 0}},
+		},
+		{
+			name:  "backtick identifier",
+			query: "SELECT `a?b`, `c``d?` FROM t WHERE x = ?",
+			want: []tok{{Text, "SELECT ", 0}, {Identifier, "`a?b`", 0}, {Text, ", ", 0}, {Identifier, "`c``d?`", 0},
+				{Text, " FROM t WHERE x = ", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "backslash does not escape backtick",
+			query: "`a\\` ?",
+			want:  []tok{{Identifier, "`a\\`", 0}, {Text, " ", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "dash comment with space",
+			query: "a -- x ?\n= ?",
+			want:  []tok{{Text, "a ", 0}, {Comment, "-- x ?", 0}, {Text, "\n= ", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "dash comment at end",
+			query: "a = ? --",
+			want:  []tok{{Text, "a = ", 0}, {Placeholder, "?", 0}, {Text, " ", 0}, {Comment, "--", 0}},
+		},
+		{
+			name:  "double minus without space is not a comment",
+			query: "a = 1--?",
+			want:  []tok{{Text, "a = 1--", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "hash comment",
+			query: "a # ? ;\n?",
+			want:  []tok{{Text, "a ", 0}, {Comment, "# ? ;", 0}, {Text, "\n", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "block comment",
+			query: "a /* ? ; */ ?",
+			want:  []tok{{Text, "a ", 0}, {Comment, "/* ? ; */", 0}, {Text, " ", 0}, {Placeholder, "?", 0}},
+		},
+		{
+			name:  "unterminated block comment",
+			query: "a /* ? ;",
+			want:  []tok{{Text, "a ", 0}, {Comment, "/* ? ;", 0}},
+		},
+		{
+			name:  "numeric placeholders",
+			query: "a = $1 AND b = $12",
+			want:  []tok{{Text, "a = ", 0}, {NumericPlaceholder, "$1", 1}, {Text, " AND b = ", 0}, {NumericPlaceholder, "$12", 12}},
+		},
+		{
+			name:  "dollar after identifier",
+			query: "SELECT a$1, x$2 FROM t WHERE b=$3",
+			want:  []tok{{Text, "SELECT a$1, x$2 FROM t WHERE b=", 0}, {NumericPlaceholder, "$3", 3}},
+		},
+		{
+			name:  "dollar without digit",
+			query: "a = $x",
+			want:  []tok{{Text, "a = $x", 0}},
+		},
+		{
+			name:  "numeric placeholder inside literal",
+			query: "'$1' = $1",
+			want:  []tok{{String, "'$1'", 0}, {Text, " = ", 0}, {NumericPlaceholder, "$1", 1}},
+		},
+		{
+			name:  "semicolons",
+			query: "a;';';b",
+			want:  []tok{{Text, "a", 0}, {Semicolon, ";", 0}, {String, "';'", 0}, {Semicolon, ";", 0}, {Text, "b", 0}},
+		},
+		{
+			name:  "empty",
+			query: "",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := Tokenize(tt.query)
+			var got []tok
+			var joined strings.Builder
+			for _, token := range tokens {
+				if tt.query[token.Pos:token.Pos+len(token.Text)] != token.Text {
+					t.Errorf("token %q has wrong Pos %d", token.Text, token.Pos)
+				}
+				got = append(got, tok{token.Kind, token.Text, token.Index})
+				joined.WriteString(token.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q)\n got %v\nwant %v", tt.query, got, tt.want)
+			}
+			if joined.String() != tt.query {
+				t.Errorf("tokens join to %q, want %q", joined.String(), tt.query)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		script string
+		want   []string
+	}{
+		{"CREATE TABLE a (x INT);\nINSERT INTO a VALUES (1);", []string{"CREATE TABLE a (x INT)", "INSERT INTO a VALUES (1)"}},
+		{"INSERT INTO a VALUES ('x;y'); -- done;\n", []string{"INSERT INTO a VALUES ('x;y')"}},
+		{"-- header\nSELECT 1; /* trailing */", []string{"-- header\nSELECT 1"}},
+		{"/* a; b */ SELECT 1;;  ;", []string{"/* a; b */ SELECT 1"}},
+		{"/*!40101 SET NAMES utf8mb4 */;\nSELECT 1", []string{"/*!40101 SET NAMES utf8mb4 */", "SELECT 1"}},
+		{"SELECT `a;b` FROM t", []string{"SELECT `a;b` FROM t"}},
+		{"  ;\n", nil},
+		{
+			"DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT '$$'; END$$\nDELIMITER ;\nCALL p();",
+			[]string{"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT '$$'; END", "CALL p()"},
+		},
+		{
+			"SELECT 1;\n-- triggers\ndelimiter //\nCREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.x = 1; END //\n" +
+				"CREATE TRIGGER u BEFORE UPDATE ON a FOR EACH ROW BEGIN SET NEW.x = 2; END//DELIMITER ;\nSELECT 2",
+			[]string{"SELECT 1", "CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.x = 1; END",
+				"CREATE TRIGGER u BEFORE UPDATE ON a FOR EACH ROW BEGIN SET NEW.x = 2; END", "SELECT 2"},
+		},
+		{"DELIMITER $$\nSELECT 1$$ /* c */ $$", []string{"SELECT 1"}},
+		{"SELECT delimiter FROM t", []string{"SELECT delimiter FROM t"}},
+	}
+	for _, tt := range tests {
+		if got := Split(tt.script); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
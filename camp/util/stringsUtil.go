@@ -7,6 +7,8 @@ import (
 )
 
 // mysql in写法
+//
+// Deprecated: 列表为[0]时不会输出任何条件，使用 db.In 生成参数化查询
 func MysqlStringInUtils(buf *strings.Builder, list []int64, preString string) {
 	list = RemoveDupsInt64(list)
 	if len(list) == 1 && list[0] == 0 {
@@ -34,6 +36,8 @@ func MysqlStringInUtils(buf *strings.Builder, list []int64, preString string) {
 }
 
 // mysql in写法
+//
+// Deprecated: 使用 db.In 生成参数化查询
 func MysqlStringInUtilsWithZero(buf *strings.Builder, list []int64, preString string) {
 	list = RemoveDupsInt64(list)
 	for k, info := range list {
@@ -58,6 +62,8 @@ func MysqlStringInUtilsWithZero(buf *strings.Builder, list []int64, preString st
 }
 
 // mysql in写法
+//
+// Deprecated: 字符串未转义，存在SQL注入风险，使用 db.In 生成参数化查询
 func MysqlInUtils(buf *strings.Builder, list []string, preString string) {
 	list = RemoveDupsString(list)
 	if len(list) == 1 && list[0] == "" {
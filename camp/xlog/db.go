@@ -1,86 +1,31 @@
 package xlog
 
 import (
-	"database/sql/driver"
 	"fmt"
-	"reflect"
-	"regexp"
 	"strconv"
 	"time"
-	"unicode"
-)
 
-var (
-	sqlRegexp                = regexp.MustCompile(`\?`)
-	numericPlaceHolderRegexp = regexp.MustCompile(`\$\d+`)
+	"github.com/mufe/golang-base/camp/sqlfmt"
 )
 
 func DB(isExec bool, useTime time.Duration, affected int64, sql string, values ...interface{}) {
-	var (
-		formattedValues []string
-		formartSql      string
-		affectedRow     string
-	)
+	// ? 和 $n 占位符都由 sqlfmt 处理，字符串和注释中的占位符不会被替换；
+	// 时间参数按UTC展示，需要按连接时区展示时使用 DBFormatted
+	formartSql := sqlfmt.Interpolate(sql, values...)
+	printDB(dbMessage(isExec, useTime, affected, formartSql))
+}
 
-	for _, value := range values {
-		indirectValue := reflect.Indirect(reflect.ValueOf(value))
-		if indirectValue.IsValid() {
-			value = indirectValue.Interface()
-			if t, ok := value.(time.Time); ok {
-				formattedValues = append(formattedValues, fmt.Sprintf("'%v'", t.Format("2006-01-02 15:04:05")))
-			} else if b, ok := value.([]byte); ok {
-				if str := string(b); isPrintable(str) {
-					formattedValues = append(formattedValues, fmt.Sprintf("'%v'", str))
-				} else {
-					formattedValues = append(formattedValues, "'<binary>'")
-				}
-			} else if r, ok := value.(driver.Valuer); ok {
-				if value, err := r.Value(); err == nil && value != nil {
-					formattedValues = append(formattedValues, fmt.Sprintf("'%v'", value))
-				} else {
-					formattedValues = append(formattedValues, "NULL")
-				}
-			} else {
-				switch value.(type) {
-				case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
-					formattedValues = append(formattedValues, fmt.Sprintf("%v", value))
-				default:
-					formattedValues = append(formattedValues, fmt.Sprintf("'%v'", value))
-				}
-			}
-		} else {
-			formattedValues = append(formattedValues, "NULL")
-		}
-	}
-	// differentiate between $n placeholders or else treat like ?
-	if numericPlaceHolderRegexp.MatchString(sql) {
-		for index, value := range formattedValues {
-			placeholder := fmt.Sprintf(`\$%d([^\d]|$)`, index+1)
-			formartSql = regexp.MustCompile(placeholder).ReplaceAllString(sql, value+"$1")
-		}
-	} else {
-		formattedValuesLength := len(formattedValues)
-		for index, value := range sqlRegexp.Split(sql, -1) {
-			formartSql += value
-			if index < formattedValuesLength {
-				formartSql += formattedValues[index]
-			}
-		}
-	}
+// DBFormatted 同 DB，sql为已嵌入参数的语句，供自行处理时区、脱敏的调用方使用
+func DBFormatted(isExec bool, useTime time.Duration, affected int64, sql string) {
+	printDB(dbMessage(isExec, useTime, affected, sql))
+}
 
+func dbMessage(isExec bool, useTime time.Duration, affected int64, sql string) string {
+	var affectedRow string
 	if isExec {
 		affectedRow = "[" + strconv.Itoa(int(affected)) + " rows affected]"
 	}
-	printDB(fmt.Sprintf("%s\n[%.2fms] %s", formartSql, float64(useTime.Nanoseconds()/1e4)/100.0, affectedRow))
-}
-
-func isPrintable(s string) bool {
-	for _, r := range s {
-		if !unicode.IsPrint(r) {
-			return false
-		}
-	}
-	return true
+	return fmt.Sprintf("%s\n[%.2fms] %s", sql, float64(useTime.Nanoseconds()/1e4)/100.0, affectedRow)
 }
 
 func printDB(s string) {